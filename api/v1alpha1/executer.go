@@ -1,7 +1,11 @@
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // ExecuterSpec defines the desired state of Executer
@@ -18,6 +22,212 @@ type ExecuterSpec struct {
 	// Replication is the replicas for the executer
 	// +kubebuilder:validation:Optional
 	Replication int32 `json:"replication,omitempty"`
+
+	// VolumeMounts is the volumes to be mounted into the executer's container
+	// +kubebuilder:validation:Optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// Files are the inline config files of the executer's container as absolute path to content,
+	// they are kept in a ConfigMap owned by the executer and the pods are restarted on their changes
+	// +kubebuilder:validation:Optional
+	Files map[string]string `json:"files,omitempty"`
+
+	// Volumes are the volumes of the executer's pods, mounted by the containers
+	// +kubebuilder:validation:Optional
+	Volumes []Volume `json:"volumes,omitempty"`
+
+	// ServiceAccountName is the service account the pods run as
+	// +kubebuilder:validation:Optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Strategy is how the pods are replaced on the changes of the executer, a rolling update by default
+	// +kubebuilder:validation:Optional
+	Strategy *Strategy `json:"strategy,omitempty"`
+
+	// RollbackTo restores the spec of the given revision of the history, it's cleared by the controller once applied
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	RollbackTo *int64 `json:"rollbackTo,omitempty"`
+
+	// Disruption creates a PodDisruptionBudget for the executer's pods,
+	// it's skipped while the replication is lower than 2 as no pod could be evicted
+	// +kubebuilder:validation:Optional
+	Disruption *Disruption `json:"disruption,omitempty"`
+
+	// RBAC provisions a ServiceAccount, Role and RoleBinding named after the executer for its pods,
+	// it can't be used together with ServiceAccountName
+	// +kubebuilder:validation:Optional
+	RBAC *RBAC `json:"rbac,omitempty"`
+
+	// SecurityContext is the pod-level security attributes, merged on top of the secure defaults of the controller
+	// +kubebuilder:validation:Optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// ContainerSecurityContext is the security attributes of the executer's container,
+	// merged on top of the secure defaults of the controller
+	// +kubebuilder:validation:Optional
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+
+	// NodeSelector restricts the pods to the nodes with these labels, e.g. a node pool
+	// +kubebuilder:validation:Optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations let the pods be scheduled on the nodes with matching taints
+	// +kubebuilder:validation:Optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity is the node affinity and pod affinity/anti-affinity rules of the pods
+	// +kubebuilder:validation:Optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// TopologySpreadConstraints controls how the pods are spread among the topology domains
+	// +kubebuilder:validation:Optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// SpreadAcrossZones adds a topology spread constraint balancing the pods across the zones in a best-effort manner
+	// +kubebuilder:validation:Optional
+	SpreadAcrossZones bool `json:"spreadAcrossZones,omitempty"`
+
+	// PriorityClassName is the priority class of the pods
+	// +kubebuilder:validation:Optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Sidecars are the containers running next to the executer's one, e.g. log shippers and proxies
+	// +kubebuilder:validation:Optional
+	Sidecars []Container `json:"sidecars,omitempty"`
+
+	// InitContainers run to completion in order before the containers start, e.g. migrations
+	// +kubebuilder:validation:Optional
+	InitContainers []Container `json:"initContainers,omitempty"`
+}
+
+// StrategyType is the type of the rollouts of an executer
+// +kubebuilder:validation:Enum=Recreate;RollingUpdate;Canary
+type StrategyType string
+
+const (
+	// StrategyRecreate kills all the pods before creating the new ones
+	StrategyRecreate StrategyType = "Recreate"
+	// StrategyRollingUpdate replaces the pods gradually
+	StrategyRollingUpdate StrategyType = "RollingUpdate"
+	// StrategyCanary runs the new pods in a second deployment and promotes them with a rolling update once they're ready
+	StrategyCanary StrategyType = "Canary"
+)
+
+// Strategy is the rollout strategy of an executer, the fields of the other types can't be set
+type Strategy struct {
+	// Type is the type of the rollouts, RollingUpdate by default
+	// +kubebuilder:validation:Optional
+	Type StrategyType `json:"type,omitempty"`
+
+	// RollingUpdate is the parameters of the rolling updates, also used for the promotions of the canaries
+	// +kubebuilder:validation:Optional
+	RollingUpdate *appsv1.RollingUpdateDeployment `json:"rollingUpdate,omitempty"`
+
+	// Canary is the parameters of the canaries
+	// +kubebuilder:validation:Optional
+	Canary *Canary `json:"canary,omitempty"`
+}
+
+// Canary gates the rollouts on a subset of the pods running the new template
+type Canary struct {
+	// Replicas is the number of the canary pods, in addition to the current ones, 1 by default
+	// +kubebuilder:validation:Optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Duration is how long all the canary pods must be ready before being promoted
+	// +kubebuilder:validation:Optional
+	Duration metav1.Duration `json:"duration,omitempty"`
+
+	// ProgressDeadline is how long the canary pods can make no progress before the canary is failed, 10 minutes by default
+	// +kubebuilder:validation:Optional
+	ProgressDeadline metav1.Duration `json:"progressDeadline,omitempty"`
+}
+
+// Disruption limits the voluntary disruptions of the executer's pods, e.g. on node drains,
+// at most one of its fields can be set and a maxUnavailable of 1 is used if none is
+type Disruption struct {
+	// MinAvailable is the number or percentage of the pods which must be available after an eviction
+	// +kubebuilder:validation:Optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// MaxUnavailable is the number or percentage of the pods which can be unavailable after an eviction
+	// +kubebuilder:validation:Optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// RBAC is the identity of the executer's pods in its namespace
+type RBAC struct {
+	// Rules are the permissions granted to the pods, limited by the rbac ceiling of the controller
+	// +kubebuilder:validation:Optional
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+}
+
+// Volume is a volume of the executer's pods, exactly one of its sources must be set
+type Volume struct {
+	// Name is the name of the volume, referenced by the volume mounts
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// ConfigMap populates the volume with the keys of a ConfigMap, the pods are restarted on its changes
+	// +kubebuilder:validation:Optional
+	ConfigMap *corev1.ConfigMapVolumeSource `json:"configMap,omitempty"`
+
+	// Secret populates the volume with the keys of a Secret, the pods are restarted on its changes
+	// +kubebuilder:validation:Optional
+	Secret *corev1.SecretVolumeSource `json:"secret,omitempty"`
+
+	// EmptyDir is a temporary directory sharing the pod's lifetime
+	// +kubebuilder:validation:Optional
+	EmptyDir *corev1.EmptyDirVolumeSource `json:"emptyDir,omitempty"`
+
+	// Projected projects multiple sources into the same directory
+	// +kubebuilder:validation:Optional
+	Projected *corev1.ProjectedVolumeSource `json:"projected,omitempty"`
+
+	// HostPath mounts a path of the node, only allowed in the privileged namespaces of the webhook
+	// +kubebuilder:validation:Optional
+	HostPath *corev1.HostPathVolumeSource `json:"hostPath,omitempty"`
+
+	// PersistentVolumeClaim references an existing claim in the executer's namespace
+	// +kubebuilder:validation:Optional
+	PersistentVolumeClaim *corev1.PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+
+	// ClaimTemplate creates a claim named <executer>-<volume> owned by the executer, shared by all of its pods.
+	// The claim isn't updated or removed by the controller afterwards to protect its data.
+	// +kubebuilder:validation:Optional
+	ClaimTemplate *corev1.PersistentVolumeClaimSpec `json:"claimTemplate,omitempty"`
+}
+
+// Container is an additional container of the executer's pods
+type Container struct {
+	// Name is the name of the container, unique among all the containers of the executer
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Image is the name of the image to be used for the container
+	// +kubebuilder:validation:Required
+	Image string `json:"image"`
+
+	// Commands is the command to be run inside the container, the image's entrypoint if empty
+	// +kubebuilder:validation:Optional
+	Commands []string `json:"commands,omitempty"`
+
+	// Env is the environment variables of the container
+	// +kubebuilder:validation:Optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Resources is the compute resources required by the container
+	// +kubebuilder:validation:Optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// VolumeMounts is the volumes of the pod to be mounted into the container
+	// +kubebuilder:validation:Optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// SecurityContext is the security attributes of the container, merged on top of the secure defaults of the controller
+	// +kubebuilder:validation:Optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
 }
 
 type Phase string
@@ -34,8 +244,45 @@ const (
 // ExecuterStatus defines the observed state of Executer
 type ExecuterStatus struct {
 	Phase Phase `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the executer's spec which is applied by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// CurrentRevision is the name of the ControllerRevision of the spec which is completely rolled out
+	// +kubebuilder:validation:Optional
+	CurrentRevision string `json:"currentRevision,omitempty"`
+
+	// UpdateRevision is the name of the ControllerRevision of the latest spec
+	// +kubebuilder:validation:Optional
+	UpdateRevision string `json:"updateRevision,omitempty"`
+
+	// LastGoodTemplate is the pod template of the last completed rollout, the failed rollouts are reverted to it
+	// +kubebuilder:validation:Optional
+	LastGoodTemplate *corev1.PodTemplateSpec `json:"lastGoodTemplate,omitempty"`
+
+	// FailedTemplate is the hash of the pod template whose rollout failed in the current generation,
+	// it isn't rolled out again until the executer or its referenced configs are changed
+	// +kubebuilder:validation:Optional
+	FailedTemplate string `json:"failedTemplate,omitempty"`
+
+	// Conditions are the latest observations of the executer's state
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:Optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// Types of the conditions of the executers
+const (
+	// ConditionDisruptionBudget reports whether the pods are protected by the PodDisruptionBudget
+	ConditionDisruptionBudget = "DisruptionBudget"
+	// ConditionCanary reports whether the pods of the running canary are ready or it's failed, it's removed when there's no canary
+	ConditionCanary = "Canary"
+	// ConditionRolledBack reports the desired template is reverted to the last good one as its rollout failed,
+	// until the executer or its referenced configs are changed
+	ConditionRolledBack = "RolledBack"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/go-logr/zapr"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/mohammadne/sanjagh/config"
+	"github.com/mohammadne/sanjagh/pkg/k8s"
+	"github.com/mohammadne/sanjagh/pkg/logger"
+	"github.com/mohammadne/sanjagh/pkg/tracing"
+	"github.com/mohammadne/sanjagh/webhook/server"
+)
+
+type AllInOne struct {
+	manager Manager
+	webhook Webhook
+}
+
+func NewAllInOne(cfg *config.Config) *cobra.Command {
+	allInOne := AllInOne{manager: Manager{config: cfg}, webhook: Webhook{config: cfg}}
+
+	cmd := &cobra.Command{
+		Use:         "all-in-one",
+		Short:       "run controller-manager and webhook servers in a single process",
+		Annotations: map[string]string{PrintConfig: "true"},
+		Run: func(_ *cobra.Command, _ []string) {
+			allInOne.main()
+		},
+	}
+
+	cmd.Flags().IntVar(&allInOne.manager.metricsPort, "metrics-bind-port", 8080, "The port the metric endpoint binds to")
+	cmd.Flags().IntVar(&allInOne.manager.probePort, "health-probe-bind-port", 8081, "The port the probe endpoint binds to")
+	cmd.Flags().IntVar(&allInOne.webhook.managementPort, "management-bind-port", 8082, "The port the webhook metric and probe endpoints binds to")
+	cmd.Flags().IntVar(&allInOne.webhook.masterPort, "master-bind-port", 8443, "The port the webhook server listens on")
+	cmd.Flags().StringVar(&allInOne.manager.kubeconfig, "kubeconfig", "", "The kubeconfig file path")
+	cmd.Flags().BoolVar(&allInOne.manager.leaderElection, "leader-elect", false, "Enable leader election for controller manager. "+
+		"Enabling this will ensure there is only one active controller manager, the webhook is served by all the replicas.")
+
+	return cmd
+}
+
+func (cmd *AllInOne) main() {
+	cfg := cmd.manager.config
+	lg := logger.NewZap(cfg.Logger)
+
+	// route the logs of controller-runtime and client-go into the configured logger
+	ctrl.SetLogger(zapr.NewLogger(lg))
+	klog.SetLogger(zapr.NewLogger(lg))
+
+	shutdown, err := tracing.New(cfg.Tracing)
+	if err != nil {
+		lg.Fatal("Unable to set up tracing", zap.Error(err))
+	}
+	defer shutdown(context.Background())
+
+	kubeConfig, err := k8s.KubeConfig(cmd.manager.kubeconfig)
+	if err != nil {
+		lg.Fatal("Unable to create kubernetes configuration", zap.Error(err))
+	}
+
+	if cfg.Tracing.Enabled {
+		kubeConfig.Wrap(tracing.Transport)
+	}
+
+	controllerRuntime := cfg.Webhook.Server.Backend == server.BackendControllerRuntime
+	if controllerRuntime {
+		cmd.manager.webhookServer = admissionServer(cfg.Webhook.Server, cmd.webhook.masterPort)
+	}
+
+	manager, reloadControllers, err := cmd.manager.newManager(kubeConfig, lg)
+	if err != nil {
+		lg.Fatal("Unable to set up manager", zap.Error(err))
+	}
+
+	// the metrics of the webhook are served by the manager next to the controllers' ones
+	if err := registerWebhookMetrics(crmetrics.Registry); err != nil {
+		lg.Fatal("Unable to register webhook metrics", zap.Error(err))
+	}
+
+	// the webhook reads from the informer cache of the manager
+	server, reload, err := newServer(cfg, manager.GetClient(), lg)
+	if err != nil {
+		lg.Fatal("Unable to set up webhook server", zap.Error(err))
+	}
+
+	if controllerRuntime {
+		server.Register(manager.GetWebhookServer())
+	} else {
+		runnable := &serverRunnable{server: server, managementPort: cmd.webhook.managementPort, masterPort: cmd.webhook.masterPort}
+		if err := manager.Add(runnable); err != nil {
+			lg.Fatal("Unable to add webhook server to the manager", zap.Error(err))
+		}
+	}
+
+	watcher := config.NewWatcher(cfg, lg)
+	watcher.OnChange(func(cfg *config.Config) {
+		logger.Reload(lg, cfg.Logger)
+		reloadControllers(cfg.Controller)
+		reload(cfg)
+	})
+
+	if err := manager.Add(watcher); err != nil {
+		lg.Fatal("Unable to set up config watcher", zap.Error(err))
+	}
+
+	lg.Info("Starting manager and webhook")
+	if err := manager.Start(ctrl.SetupSignalHandler()); err != nil {
+		lg.Info("Problem running manager", zap.Error(err))
+	}
+}
+
+// serverRunnable runs the webhook server within the manager
+type serverRunnable struct {
+	server         *server.Server
+	managementPort int
+	masterPort     int
+}
+
+func (runnable *serverRunnable) Start(ctx context.Context) error {
+	return runnable.server.Start(ctx, runnable.managementPort, runnable.masterPort)
+}
+
+// NeedLeaderElection is false as the webhook is served by all the replicas
+func (*serverRunnable) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+func TestAllInOneMetrics(t *testing.T) {
+	// the all-in-one command serves the webhook metrics by the registry of the manager
+	require.NoError(t, registerWebhookMetrics(crmetrics.Registry))
+
+	families, err := crmetrics.Registry.Gather()
+	require.NoError(t, err)
+
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.Contains(t, names, "sanjagh_audit_dropped_records_total")
+}
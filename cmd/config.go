@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mohammadne/sanjagh/config"
+)
+
+// PrintConfig is the command annotation for printing the loaded configuration on startup
+const PrintConfig = "sanjagh.mohammadne.me/print-config"
+
+func NewConfig() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "inspect sanjagh configuration",
+
+		// the config commands work on their own arguments instead of the loaded configuration
+		PersistentPreRun: func(_ *cobra.Command, _ []string) {},
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "validate <file>",
+			Short: "validate the configuration file on top of the default values",
+			Args:  cobra.ExactArgs(1),
+
+			SilenceUsage: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if _, err := config.LoadFile(args[0]); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "%s is valid\n", args[0])
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "schema",
+			Short: "print the JSON Schema of the configuration",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				schema, err := config.Schema()
+				if err != nil {
+					return err
+				}
+
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(schema)
+			},
+		},
+	)
+
+	return cmd
+}
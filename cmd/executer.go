@@ -0,0 +1,562 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/config"
+	"github.com/mohammadne/sanjagh/controllers/apps"
+	"github.com/mohammadne/sanjagh/pkg/k8s"
+)
+
+// PluginName is the binary name which makes sanjagh a kubectl plugin, used as `kubectl executer`
+const PluginName = "kubectl-executer"
+
+type Executer struct {
+	config        *config.Config
+	kubeconfig    string
+	namespace     string
+	allNamespaces bool
+
+	client    crclient.Client
+	clientset kubernetes.Interface
+}
+
+func NewExecuter(cfg *config.Config) *cobra.Command {
+	executer := Executer{config: cfg}
+
+	cmd := &cobra.Command{
+		Use:          "executer",
+		Short:        "operate the Executers of the cluster",
+		SilenceUsage: true,
+	}
+
+	cmd.PersistentFlags().StringVar(&executer.kubeconfig, "kubeconfig", "", "The kubeconfig file path")
+	cmd.PersistentFlags().StringVarP(&executer.namespace, "namespace", "n", "", "The namespace of the Executers, defaults to the one of the current context")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "list the Executers with their phase and ready replicas",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return executer.list(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+	list.Flags().BoolVarP(&executer.allNamespaces, "all-namespaces", "A", false, "List the Executers of all namespaces")
+
+	var replicas int32
+	scale := &cobra.Command{
+		Use:   "scale <name> --replicas <count>",
+		Short: "set the replication of an Executer within the webhook bounds",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executer.scale(cmd.Context(), cmd.OutOrStdout(), args[0], replicas)
+		},
+	}
+	scale.Flags().Int32Var(&replicas, "replicas", 0, "The desired replication")
+	_ = scale.MarkFlagRequired("replicas")
+
+	restart := &cobra.Command{
+		Use:   "restart <name>",
+		Short: "rollout the pods of the Deployment owned by an Executer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executer.restart(cmd.Context(), cmd.OutOrStdout(), args[0])
+		},
+	}
+
+	logOptions := corev1.PodLogOptions{}
+	var tail int64
+	var track string
+	logs := &cobra.Command{
+		Use:   "logs <name>",
+		Short: "print the logs of all the pods of an Executer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if tail >= 0 {
+				logOptions.TailLines = &tail
+			}
+			return executer.logs(cmd.Context(), cmd.OutOrStdout(), args[0], track, logOptions)
+		},
+	}
+	logs.Flags().BoolVarP(&logOptions.Follow, "follow", "f", false, "Stream the logs")
+	logs.Flags().Int64Var(&tail, "tail", -1, "The number of recent lines of each pod, all of them if negative")
+	logs.Flags().StringVarP(&logOptions.Container, "container", "c", "", "The container of the pods, defaults to the executer one")
+	logs.Flags().StringVar(&track, "track", "", "The track of the pods, stable or canary, defaults to both of them")
+
+	describe := &cobra.Command{
+		Use:   "describe <name>",
+		Short: "show an Executer with its Deployment, pods and events",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executer.describe(cmd.Context(), cmd.OutOrStdout(), args[0])
+		},
+	}
+
+	history := &cobra.Command{
+		Use:   "history <name>",
+		Short: "list the revisions of the spec of an Executer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executer.history(cmd.Context(), cmd.OutOrStdout(), args[0])
+		},
+	}
+
+	var revision int64
+	rollback := &cobra.Command{
+		Use:   "rollback <name>",
+		Short: "restore a previous revision of the spec of an Executer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executer.rollback(cmd.Context(), cmd.OutOrStdout(), args[0], revision)
+		},
+	}
+	rollback.Flags().Int64Var(&revision, "to-revision", 0, "The revision to restore, defaults to the one before the current revision")
+
+	cmd.AddCommand(list, scale, restart, logs, describe, history, rollback)
+	return cmd
+}
+
+// connect creates the clients and resolves the namespace from the kubeconfig, the clients already set are kept
+func (cmd *Executer) connect() error {
+	if cmd.client != nil {
+		return nil
+	}
+
+	kubeConfig, err := k8s.KubeConfig(cmd.kubeconfig)
+	if err != nil {
+		return fmt.Errorf("error loading kubeconfig: %v", err)
+	}
+
+	if cmd.namespace == "" {
+		if cmd.namespace, err = k8s.Namespace(cmd.kubeconfig); err != nil {
+			return fmt.Errorf("error getting namespace of kubeconfig: %v", err)
+		}
+	}
+
+	if cmd.client, err = crclient.New(kubeConfig, crclient.Options{Scheme: newScheme()}); err != nil {
+		return fmt.Errorf("error creating kubernetes client: %v", err)
+	}
+
+	if cmd.clientset, err = kubernetes.NewForConfig(kubeConfig); err != nil {
+		return fmt.Errorf("error creating kubernetes clientset: %v", err)
+	}
+
+	return nil
+}
+
+func (cmd *Executer) get(ctx context.Context, name string) (*appsv1alpha1.Executer, error) {
+	if err := cmd.connect(); err != nil {
+		return nil, err
+	}
+
+	executer := &appsv1alpha1.Executer{}
+	if err := cmd.client.Get(ctx, types.NamespacedName{Namespace: cmd.namespace, Name: name}, executer); err != nil {
+		return nil, fmt.Errorf("error getting executer %s/%s: %v", cmd.namespace, name, err)
+	}
+
+	return executer, nil
+}
+
+// deployment returns the deployment owned by the executer, nil if it isn't created yet
+func (cmd *Executer) deployment(ctx context.Context, executer *appsv1alpha1.Executer) (*appsv1.Deployment, error) {
+	deployment := &appsv1.Deployment{}
+	if err := cmd.client.Get(ctx, crclient.ObjectKeyFromObject(executer), deployment); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting deployment %s/%s: %v", executer.Namespace, executer.Name, err)
+	}
+
+	return deployment, nil
+}
+
+// pods returns the pods of the executer's track, the ones of all the tracks if it's empty
+func (cmd *Executer) pods(ctx context.Context, executer *appsv1alpha1.Executer, track string) ([]corev1.Pod, error) {
+	labels := apps.Labels(executer)
+	if track != "" {
+		labels = apps.TrackLabels(executer, track)
+	}
+
+	pods := &corev1.PodList{}
+	err := cmd.client.List(ctx, pods, crclient.InNamespace(executer.Namespace), crclient.MatchingLabels(labels))
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods of executer %s/%s: %v", executer.Namespace, executer.Name, err)
+	}
+
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+	return pods.Items, nil
+}
+
+func (cmd *Executer) list(ctx context.Context, out io.Writer) error {
+	if err := cmd.connect(); err != nil {
+		return err
+	}
+
+	options := []crclient.ListOption{}
+	if !cmd.allNamespaces {
+		options = append(options, crclient.InNamespace(cmd.namespace))
+	}
+
+	executers := &appsv1alpha1.ExecuterList{}
+	if err := cmd.client.List(ctx, executers, options...); err != nil {
+		return fmt.Errorf("error listing executers: %v", err)
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(writer, "NAMESPACE\tNAME\tPHASE\tREADY\tIMAGE\tAGE")
+	for index := range executers.Items {
+		executer := &executers.Items[index]
+
+		deployment, err := cmd.deployment(ctx, executer)
+		if err != nil {
+			return err
+		}
+
+		var ready int32
+		if deployment != nil {
+			ready = deployment.Status.ReadyReplicas
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d/%d\t%s\t%s\n", executer.Namespace, executer.Name, executer.Status.Phase,
+			ready, executer.Spec.Replication, executer.Spec.Image, age(executer.CreationTimestamp.Time))
+	}
+
+	return writer.Flush()
+}
+
+func (cmd *Executer) scale(ctx context.Context, out io.Writer, name string, replicas int32) error {
+	executer, err := cmd.get(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	// the bounds are checked by the admission webhook of the cluster, as its configuration may differ from the local one
+	patch := crclient.MergeFrom(executer.DeepCopy())
+	executer.Spec.Replication = replicas
+	if err := cmd.client.Patch(ctx, executer, patch); err != nil {
+		return fmt.Errorf("error scaling executer %s/%s: %v", executer.Namespace, executer.Name, err)
+	}
+
+	fmt.Fprintf(out, "executer %s/%s scaled to %d replicas\n", executer.Namespace, executer.Name, replicas)
+	return nil
+}
+
+// restartedAtAnnotation is the same annotation used by `kubectl rollout restart`
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+func (cmd *Executer) restart(ctx context.Context, out io.Writer, name string) error {
+	executer, err := cmd.get(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	deployment, err := cmd.deployment(ctx, executer)
+	if err != nil {
+		return err
+	} else if deployment == nil {
+		return fmt.Errorf("the deployment of executer %s/%s is not created yet", executer.Namespace, executer.Name)
+	}
+
+	patch := crclient.MergeFrom(deployment.DeepCopy())
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[restartedAtAnnotation] = time.Now().Format(time.RFC3339)
+	if err := cmd.client.Patch(ctx, deployment, patch); err != nil {
+		return fmt.Errorf("error restarting deployment %s/%s: %v", deployment.Namespace, deployment.Name, err)
+	}
+
+	fmt.Fprintf(out, "executer %s/%s restarted\n", executer.Namespace, executer.Name)
+	return nil
+}
+
+func (cmd *Executer) history(ctx context.Context, out io.Writer, name string) error {
+	executer, err := cmd.get(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := apps.Revisions(ctx, cmd.client, executer)
+	if err != nil {
+		return fmt.Errorf("error listing revisions of executer %s/%s: %v", executer.Namespace, executer.Name, err)
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(writer, "REVISION\tNAME\tIMAGE\tREPLICATION\tSTATUS\tAGE")
+	for index := range revisions {
+		revision := &revisions[index]
+		spec, err := apps.RevisionSpec(revision)
+		if err != nil {
+			return err
+		}
+
+		var status []string
+		if revision.Name == executer.Status.CurrentRevision {
+			status = append(status, "current")
+		}
+		if revision.Name == executer.Status.UpdateRevision {
+			status = append(status, "update")
+		}
+		if len(status) == 0 {
+			status = append(status, "-")
+		}
+
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%d\t%s\t%s\n", revision.Revision, revision.Name, spec.Image,
+			spec.Replication, strings.Join(status, ","), age(revision.CreationTimestamp.Time))
+	}
+
+	return writer.Flush()
+}
+
+// rollback requests the controller to restore the revision, 0 is the one before the current revision
+func (cmd *Executer) rollback(ctx context.Context, out io.Writer, name string, revision int64) error {
+	executer, err := cmd.get(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := apps.Revisions(ctx, cmd.client, executer)
+	if err != nil {
+		return fmt.Errorf("error listing revisions of executer %s/%s: %v", executer.Namespace, executer.Name, err)
+	}
+
+	// the revisions are sorted, so the last one before the current revision is the previous one
+	var current, previous int64
+	for _, item := range revisions {
+		if item.Name == executer.Status.CurrentRevision {
+			current = item.Revision
+		}
+	}
+	for _, item := range revisions {
+		if item.Revision < current {
+			previous = item.Revision
+		}
+	}
+	if revision == 0 {
+		revision = previous
+	}
+
+	found := false
+	for _, item := range revisions {
+		found = found || item.Revision == revision
+	}
+	if !found {
+		return fmt.Errorf("revision %d of executer %s/%s isn't found in the history", revision, executer.Namespace, executer.Name)
+	}
+
+	patch := crclient.MergeFrom(executer.DeepCopy())
+	executer.Spec.RollbackTo = &revision
+	if err := cmd.client.Patch(ctx, executer, patch); err != nil {
+		return fmt.Errorf("error rolling back executer %s/%s: %v", executer.Namespace, executer.Name, err)
+	}
+
+	fmt.Fprintf(out, "executer %s/%s rolled back to revision %d\n", executer.Namespace, executer.Name, revision)
+	return nil
+}
+
+func (cmd *Executer) logs(ctx context.Context, out io.Writer, name, track string, options corev1.PodLogOptions) error {
+	if track != "" && track != apps.TrackStable && track != apps.TrackCanary {
+		return fmt.Errorf("invalid track %q, it should be %s or %s", track, apps.TrackStable, apps.TrackCanary)
+	}
+
+	executer, err := cmd.get(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	pods, err := cmd.pods(ctx, executer, track)
+	if err != nil {
+		return err
+	} else if len(pods) == 0 {
+		return fmt.Errorf("no pods found for executer %s/%s", executer.Namespace, executer.Name)
+	}
+
+	if options.Container == "" {
+		options.Container = executer.Name
+	}
+
+	// the lines of the pods are interleaved, so each one is prefixed by its pod name
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, len(pods))
+	for index := range pods {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+
+			pod := pods[index].Name
+			stream, err := cmd.clientset.CoreV1().Pods(executer.Namespace).GetLogs(pod, &options).Stream(ctx)
+			if err != nil {
+				errs[index] = fmt.Errorf("error getting logs of pod %s: %v", pod, err)
+				return
+			}
+			defer stream.Close()
+
+			scanner := bufio.NewScanner(stream)
+			for scanner.Scan() {
+				mutex.Lock()
+				fmt.Fprintf(out, "[%s] %s\n", pod, scanner.Text())
+				mutex.Unlock()
+			}
+
+			if err := scanner.Err(); err != nil {
+				errs[index] = fmt.Errorf("error reading logs of pod %s: %v", pod, err)
+			}
+		}(index)
+	}
+
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+func (cmd *Executer) describe(ctx context.Context, out io.Writer, name string) error {
+	executer, err := cmd.get(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	deployment, err := cmd.deployment(ctx, executer)
+	if err != nil {
+		return err
+	}
+
+	pods, err := cmd.pods(ctx, executer, "")
+	if err != nil {
+		return err
+	}
+
+	events, err := cmd.events(ctx, executer, deployment, pods)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "Name:\t%s\n", executer.Name)
+	fmt.Fprintf(writer, "Namespace:\t%s\n", executer.Namespace)
+	fmt.Fprintf(writer, "Image:\t%s\n", executer.Spec.Image)
+	fmt.Fprintf(writer, "Commands:\t%s\n", strings.Join(executer.Spec.Commands, " "))
+	fmt.Fprintf(writer, "Replication:\t%d\n", executer.Spec.Replication)
+	for _, sidecar := range executer.Spec.Sidecars {
+		fmt.Fprintf(writer, "Sidecar:\t%s (%s)\n", sidecar.Name, sidecar.Image)
+	}
+	for _, container := range executer.Spec.InitContainers {
+		fmt.Fprintf(writer, "Init Container:\t%s (%s)\n", container.Name, container.Image)
+	}
+	fmt.Fprintf(writer, "Phase:\t%s\n", executer.Status.Phase)
+	fmt.Fprintf(writer, "Observed Generation:\t%d/%d\n", executer.Status.ObservedGeneration, executer.Generation)
+	fmt.Fprintf(writer, "Revisions:\t%s current | %s update\n", executer.Status.CurrentRevision, executer.Status.UpdateRevision)
+	for _, condition := range executer.Status.Conditions {
+		fmt.Fprintf(writer, "%s:\t%s (%s)\n", condition.Type, condition.Status, condition.Reason)
+	}
+	fmt.Fprintf(writer, "Age:\t%s\n", age(executer.CreationTimestamp.Time))
+
+	fmt.Fprintln(writer, "\nDeployment:")
+	if deployment == nil {
+		fmt.Fprintln(writer, "  <not created>")
+	} else {
+		status := deployment.Status
+		fmt.Fprintf(writer, "  Name:\t%s\n", deployment.Name)
+		fmt.Fprintf(writer, "  Replicas:\t%d desired | %d updated | %d ready | %d available\n",
+			*deployment.Spec.Replicas, status.UpdatedReplicas, status.ReadyReplicas, status.AvailableReplicas)
+		for _, condition := range status.Conditions {
+			fmt.Fprintf(writer, "  %s:\t%s (%s)\n", condition.Type, condition.Status, condition.Reason)
+		}
+	}
+
+	fmt.Fprintln(writer, "\nPods:")
+	if len(pods) == 0 {
+		fmt.Fprintln(writer, "  <none>")
+	} else {
+		fmt.Fprintln(writer, "  NAME\tTRACK\tREADY\tSTATUS\tRESTARTS\tAGE")
+	}
+	for _, pod := range pods {
+		var ready, restarts int32
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Ready {
+				ready++
+			}
+			restarts += status.RestartCount
+		}
+
+		fmt.Fprintf(writer, "  %s\t%s\t%d/%d\t%s\t%d\t%s\n", pod.Name, pod.Labels[apps.TrackLabel], ready, len(pod.Spec.Containers), pod.Status.Phase, restarts, age(pod.CreationTimestamp.Time))
+	}
+
+	fmt.Fprintln(writer, "\nEvents:")
+	if len(events) == 0 {
+		fmt.Fprintln(writer, "  <none>")
+	} else {
+		fmt.Fprintln(writer, "  TYPE\tREASON\tAGE\tOBJECT\tMESSAGE")
+	}
+	for _, event := range events {
+		object := strings.ToLower(event.InvolvedObject.Kind) + "/" + event.InvolvedObject.Name
+		fmt.Fprintf(writer, "  %s\t%s\t%s\t%s\t%s\n", event.Type, event.Reason, age(eventTime(event)), object, event.Message)
+	}
+
+	return writer.Flush()
+}
+
+// events returns the events of the executer, its deployment and pods, the oldest first
+func (cmd *Executer) events(ctx context.Context, executer *appsv1alpha1.Executer, deployment *appsv1.Deployment, pods []corev1.Pod) ([]corev1.Event, error) {
+	uids := map[types.UID]bool{executer.UID: true}
+	if deployment != nil {
+		uids[deployment.UID] = true
+	}
+	for index := range pods {
+		uids[pods[index].UID] = true
+	}
+
+	// the events of the namespace are listed at once instead of a request per involved object
+	list, err := cmd.clientset.CoreV1().Events(executer.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing events of executer %s/%s: %v", executer.Namespace, executer.Name, err)
+	}
+
+	var events []corev1.Event
+	for _, event := range list.Items {
+		if uids[event.InvolvedObject.UID] {
+			events = append(events, event)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	return events, nil
+}
+
+// eventTime returns the last time the event happened
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	} else if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+func age(timestamp time.Time) string {
+	if timestamp.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(timestamp))
+}
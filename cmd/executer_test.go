@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/config"
+	"github.com/mohammadne/sanjagh/controllers/apps"
+)
+
+// newTestExecuter returns the command connected to a fake client holding the executer and its revisions,
+// the current revision is the given one
+func newTestExecuter(t *testing.T, revisions []int64, current int64) (*Executer, crclient.Client) {
+	cfg, err := config.Load(config.Sources{}, false)
+	require.NoError(t, err)
+
+	scheme := newScheme()
+	executer := &appsv1alpha1.Executer{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default", UID: "worker-uid"},
+		Spec:       appsv1alpha1.ExecuterSpec{Image: "worker:v1", Replication: 2},
+		Status:     appsv1alpha1.ExecuterStatus{CurrentRevision: revisionName(current)},
+	}
+
+	objects := []crclient.Object{executer}
+	for _, number := range revisions {
+		revision := &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: revisionName(number), Namespace: "default", Labels: apps.Labels(executer)},
+			Data:       runtime.RawExtension{Raw: []byte(`{"image":"worker:v1","replication":2}`)},
+			Revision:   number,
+		}
+		require.NoError(t, ctrl.SetControllerReference(executer, revision, scheme))
+		objects = append(objects, revision)
+	}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	return &Executer{config: cfg, namespace: "default", client: client}, client
+}
+
+func revisionName(number int64) string {
+	return fmt.Sprintf("worker-%d", number)
+}
+
+// deniedPatches is the client rejecting the patches as the admission webhook of the cluster does
+type deniedPatches struct {
+	crclient.Client
+	message string
+}
+
+func (client deniedPatches) Patch(context.Context, crclient.Object, crclient.Patch, ...crclient.PatchOption) error {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusForbidden,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: `admission webhook "executers.sanjagh.mohammadne.me" denied the request: ` + client.message,
+	}}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int32
+		denied   string
+		err      string
+		stored   int32
+	}{
+		{name: "accepted", replicas: 4, stored: 4},
+		// the local bounds don't apply, the ones of the cluster may differ
+		{name: "beyond the local bounds", replicas: 9, stored: 9},
+		{
+			name:     "denied by the webhook",
+			replicas: 9,
+			denied:   "Replication exceeds the maximum value: '8'",
+			err: "error scaling executer default/worker: " +
+				`admission webhook "executers.sanjagh.mohammadne.me" denied the request: Replication exceeds the maximum value: '8'`,
+			stored: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, client := newTestExecuter(t, nil, 0)
+			if test.denied != "" {
+				cmd.client = deniedPatches{Client: client, message: test.denied}
+			}
+
+			out := &bytes.Buffer{}
+			err := cmd.scale(context.Background(), out, "worker", test.replicas)
+			if test.err == "" {
+				require.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("executer default/worker scaled to %d replicas\n", test.replicas), out.String())
+			} else {
+				assert.EqualError(t, err, test.err)
+				assert.Empty(t, out.String())
+			}
+
+			executer := &appsv1alpha1.Executer{}
+			require.NoError(t, client.Get(context.Background(), crclient.ObjectKey{Namespace: "default", Name: "worker"}, executer))
+			assert.Equal(t, test.stored, executer.Spec.Replication)
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name      string
+		revisions []int64
+		current   int64
+		revision  int64
+		restored  int64
+		err       string
+	}{
+		{name: "previous of the latest", revisions: []int64{1, 2, 3}, current: 3, restored: 2},
+		{name: "previous of an older current", revisions: []int64{1, 2, 3}, current: 2, restored: 1},
+		{name: "previous with pruned revisions", revisions: []int64{2, 5, 7}, current: 7, restored: 5},
+		{name: "requested revision", revisions: []int64{1, 2, 3}, current: 3, revision: 1, restored: 1},
+		{name: "no previous revision", revisions: []int64{1}, current: 1, err: "revision 0 of executer default/worker isn't found in the history"},
+		{name: "missing revision", revisions: []int64{1, 2}, current: 2, revision: 4, err: "revision 4 of executer default/worker isn't found in the history"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, client := newTestExecuter(t, test.revisions, test.current)
+
+			out := &bytes.Buffer{}
+			err := cmd.rollback(context.Background(), out, "worker", test.revision)
+
+			executer := &appsv1alpha1.Executer{}
+			require.NoError(t, client.Get(context.Background(), crclient.ObjectKey{Namespace: "default", Name: "worker"}, executer))
+
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				assert.Nil(t, executer.Spec.RollbackTo)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, executer.Spec.RollbackTo)
+			assert.Equal(t, test.restored, *executer.Spec.RollbackTo)
+		})
+	}
+}
+
+func TestDescribeEvents(t *testing.T) {
+	cmd, client := newTestExecuter(t, nil, 0)
+	ctx := context.Background()
+
+	executer, err := cmd.get(ctx, "worker")
+	require.NoError(t, err)
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default", UID: "deployment-uid"}}
+	deployment.Spec.Replicas = pointer.Int32(2)
+	require.NoError(t, client.Create(ctx, deployment))
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Namespace: "default", UID: "pod-uid", Labels: apps.Labels(executer)}}
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other-1", Namespace: "default", UID: "other-uid"}}
+	require.NoError(t, client.Create(ctx, pod))
+	require.NoError(t, client.Create(ctx, other))
+
+	now := time.Now()
+	event := func(name, kind string, object crclient.Object, reason string, ago time.Duration) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object.GetName(), UID: object.GetUID()},
+			Type:           corev1.EventTypeNormal,
+			Reason:         reason,
+			LastTimestamp:  metav1.NewTime(now.Add(-ago)),
+		}
+	}
+	cmd.clientset = kubefake.NewSimpleClientset(
+		event("pulled", "Pod", pod, "Pulled", time.Minute),
+		event("created", "Executer", executer, "DeploymentCreated", 3*time.Minute),
+		event("scaled", "Deployment", deployment, "ScalingReplicaSet", 2*time.Minute),
+		event("unrelated", "Pod", other, "Killing", time.Minute),
+	)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, cmd.describe(ctx, out, "worker"))
+
+	output := out.String()
+	require.Contains(t, output, "Events:\n")
+	var objects []string
+	for _, line := range strings.Split(strings.SplitN(output, "Events:\n", 2)[1], "\n")[1:] {
+		if fields := strings.Fields(line); len(fields) > 3 {
+			objects = append(objects, fields[1]+" "+fields[len(fields)-1])
+		}
+	}
+	assert.Equal(t, []string{"DeploymentCreated executer/worker", "ScalingReplicaSet deployment/worker", "Pulled pod/worker-1"}, objects)
+}
@@ -1,22 +1,31 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/go-logr/zapr"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
 	"github.com/mohammadne/sanjagh/config"
 	"github.com/mohammadne/sanjagh/controllers"
+	"github.com/mohammadne/sanjagh/controllers/apps"
+	"github.com/mohammadne/sanjagh/controllers/metrics"
 	"github.com/mohammadne/sanjagh/pkg/k8s"
 	"github.com/mohammadne/sanjagh/pkg/logger"
+	"github.com/mohammadne/sanjagh/pkg/tracing"
 )
 
 type Manager struct {
@@ -25,14 +34,18 @@ type Manager struct {
 	probePort      int
 	leaderElection bool
 	kubeconfig     string
+
+	// webhookServer serves the webhook within the manager, if set
+	webhookServer *webhook.Server
 }
 
 func NewManager(cfg *config.Config) *cobra.Command {
 	manager := Manager{config: cfg}
 
 	cmd := &cobra.Command{
-		Use:   "manager",
-		Short: "run controller-manager server",
+		Use:         "manager",
+		Short:       "run controller-manager server",
+		Annotations: map[string]string{PrintConfig: "true"},
 		Run: func(_ *cobra.Command, _ []string) {
 			manager.main()
 		},
@@ -48,47 +61,98 @@ func NewManager(cfg *config.Config) *cobra.Command {
 }
 
 func (cmd *Manager) main() {
-	logger := logger.NewZap(cmd.config.Logger)
+	lg := logger.NewZap(cmd.config.Logger)
+
+	// route the logs of controller-runtime and client-go into the configured logger
+	ctrl.SetLogger(zapr.NewLogger(lg))
+	klog.SetLogger(zapr.NewLogger(lg))
+
+	shutdown, err := tracing.New(cmd.config.Tracing)
+	if err != nil {
+		lg.Fatal("Unable to set up tracing", zap.Error(err))
+	}
+	defer shutdown(context.Background())
 
 	kubeConfig, err := k8s.KubeConfig(cmd.kubeconfig)
 	if err != nil {
-		logger.Fatal("Unable to create kubernetes configuration", zap.Error(err))
+		lg.Fatal("Unable to create kubernetes configuration", zap.Error(err))
 	}
 
+	if cmd.config.Tracing.Enabled {
+		kubeConfig.Wrap(tracing.Transport)
+	}
+
+	manager, reload, err := cmd.newManager(kubeConfig, lg)
+	if err != nil {
+		lg.Fatal("Unable to set up manager", zap.Error(err))
+	}
+
+	watcher := config.NewWatcher(cmd.config, lg)
+	watcher.OnChange(func(cfg *config.Config) {
+		logger.Reload(lg, cfg.Logger)
+		reload(cfg.Controller)
+	})
+
+	if err := manager.Add(watcher); err != nil {
+		lg.Fatal("Unable to set up config watcher", zap.Error(err))
+	}
+
+	lg.Info("Starting manager")
+	if err := manager.Start(ctrl.SetupSignalHandler()); err != nil {
+		lg.Info("Problem running manager", zap.Error(err))
+	}
+}
+
+// newManager creates the manager with the controllers, metrics and probes registered,
+// the returned function applies a new config to the controllers
+func (cmd *Manager) newManager(kubeConfig *rest.Config, lg *zap.Logger) (ctrl.Manager, func(*apps.Config), error) {
 	manager, err := ctrl.NewManager(kubeConfig, cmd.options())
 	if err != nil {
-		logger.Fatal("Unable to start manager", zap.Error(err))
+		return nil, nil, fmt.Errorf("error creating manager: %v", err)
 	}
 
-	if err := controllers.Register(manager, logger); err != nil {
-		logger.Fatal("Unable to register controllers", zap.Error(err))
+	reload, err := controllers.Register(manager, cmd.config.Controller, lg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error registering controllers: %v", err)
 	}
 
-	// TODO: add your custom metrics here
+	if err := metrics.Register(); err != nil {
+		return nil, nil, fmt.Errorf("error registering custom metrics: %v", err)
+	}
+
+	if err := crmetrics.Registry.Register(config.Reloads); err != nil {
+		return nil, nil, fmt.Errorf("error registering config metrics: %v", err)
+	}
+
+	if err := manager.AddMetricsExtraHandler("/log/level", logger.LevelHandler(lg)); err != nil {
+		return nil, nil, fmt.Errorf("error setting up log level handler: %v", err)
+	}
 
 	if err := manager.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		logger.Fatal("Unable to set up health check", zap.Error(err))
+		return nil, nil, fmt.Errorf("error setting up health check: %v", err)
 	}
 	if err := manager.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		logger.Fatal("Unable to set up ready check", zap.Error(err))
+		return nil, nil, fmt.Errorf("error setting up ready check: %v", err)
 	}
 
-	logger.Info("Starting manager")
-	if err := manager.Start(ctrl.SetupSignalHandler()); err != nil {
-		logger.Info("Problem running manager", zap.Error(err))
-	}
+	return manager, reload, nil
 }
 
 func (cmd *Manager) options() ctrl.Options {
-	var scheme = runtime.NewScheme()
-	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
-	utilruntime.Must(appsv1alpha1.AddToScheme(scheme))
-
 	return ctrl.Options{
-		Scheme:                 scheme,
+		Scheme:                 newScheme(),
 		MetricsBindAddress:     fmt.Sprintf(":%d", cmd.metricsPort),
 		HealthProbeBindAddress: fmt.Sprintf(":%d", cmd.probePort),
 		LeaderElection:         cmd.leaderElection,
 		LeaderElectionID:       "eca9d324.mohammadne.me",
+		WebhookServer:          cmd.webhookServer,
 	}
 }
+
+// newScheme contains the kubernetes builtin types alongside the sanjagh ones
+func newScheme() *runtime.Scheme {
+	var scheme = runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(appsv1alpha1.AddToScheme(scheme))
+	return scheme
+}
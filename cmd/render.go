@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/config"
+	"github.com/mohammadne/sanjagh/controllers/apps"
+	"github.com/mohammadne/sanjagh/pkg/k8s"
+)
+
+type Render struct {
+	config     *config.Config
+	client     crclient.Client
+	namespace  string
+	diff       bool
+	kubeconfig string
+}
+
+func NewRender(cfg *config.Config) *cobra.Command {
+	render := Render{config: cfg}
+
+	cmd := &cobra.Command{
+		Use:   "render [file | directory | -]...",
+		Short: "render the objects the controller creates for Executer manifests",
+		Long: "Render the objects the controller creates for Executer manifests, including their labels and owner references.\n" +
+			"With --diff the live objects are compared with them as the controller updates them, or deletes them once they aren't needed.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return render.main(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout(), args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&render.namespace, "namespace", "n", "default", "The namespace of the Executers without one")
+	cmd.Flags().BoolVar(&render.diff, "diff", false, "Show the difference with the live objects instead of the rendered ones")
+	cmd.Flags().StringVar(&render.kubeconfig, "kubeconfig", "", "The kubeconfig file path, used with --diff")
+
+	return cmd
+}
+
+// noisyFields are set by the api-server and are dropped from the printed objects
+var noisyFields = [][]string{
+	{"status"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "generation"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "selfLink"},
+	{"metadata", "uid"},
+}
+
+func (cmd *Render) main(ctx context.Context, stdin io.Reader, out io.Writer, paths []string) error {
+	manifests, err := readManifests(stdin, paths)
+	if err != nil {
+		return err
+	}
+
+	scheme := newScheme()
+
+	client := cmd.client
+	if cmd.diff && client == nil {
+		kubeConfig, err := k8s.KubeConfig(cmd.kubeconfig)
+		if err != nil {
+			return fmt.Errorf("error loading kubeconfig: %v", err)
+		}
+
+		if client, err = crclient.New(kubeConfig, crclient.Options{Scheme: scheme}); err != nil {
+			return fmt.Errorf("error creating kubernetes client: %v", err)
+		}
+	}
+
+	for _, manifest := range manifests {
+		executer := &appsv1alpha1.Executer{}
+		if err := utilyaml.Unmarshal(manifest.raw, executer); err != nil {
+			return fmt.Errorf("%s: %v", manifest.source, err)
+		}
+
+		gvk := executer.GroupVersionKind()
+		if gvk.Group != appsv1alpha1.GroupVersion.Group || gvk.Kind != "Executer" {
+			continue
+		}
+
+		if executer.Namespace == "" {
+			executer.Namespace = cmd.namespace
+		}
+
+		live := &appsv1alpha1.Executer{}
+		if cmd.diff {
+			if err := client.Get(ctx, crclient.ObjectKeyFromObject(executer), live); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("error getting executer %s/%s: %v", executer.Namespace, executer.Name, err)
+			}
+
+			// the owner references point to the live executer
+			executer.UID = live.UID
+		}
+
+		objects, err := apps.DesiredObjects(executer, cmd.config.Controller, scheme)
+		if err != nil {
+			return fmt.Errorf("%s: %v", manifest.source, err)
+		}
+
+		if !cmd.diff {
+			for _, object := range objects {
+				content, err := toYAML(scheme, object)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "---\n%s", content)
+			}
+			continue
+		}
+
+		// the checksum of the referenced configs is only known with the live objects
+		checksum, err := apps.ConfigChecksum(ctx, client, executer)
+		if err != nil {
+			return fmt.Errorf("error computing config checksum of executer %s/%s: %v", executer.Namespace, executer.Name, err)
+		}
+
+		for _, object := range objects {
+			if deployment, ok := object.(*appsv1.Deployment); ok {
+				apps.SetConfigChecksum(deployment, checksum)
+			}
+		}
+
+		if err := diff(ctx, client, scheme, out, live, executer, objects); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diff prints the unified diffs of the live objects and the objects as the controller leaves them,
+// the desired ones are synced like the controller does and the ones it doesn't need anymore are deleted
+func diff(ctx context.Context, client crclient.Client, scheme *runtime.Scheme, out io.Writer,
+	live, executer *appsv1alpha1.Executer, objects []crclient.Object) error {
+	desired := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		// the executer doesn't exist yet, so its objects can't be owned by it
+		if live.UID == "" {
+			object.SetOwnerReferences(nil)
+		}
+
+		name, err := objectKey(scheme, object)
+		if err != nil {
+			return err
+		}
+		desired[name] = true
+
+		found, err := getObject(ctx, client, scheme, object)
+		if err != nil {
+			return err
+		}
+
+		synced := object
+		if found != nil {
+			synced = found.DeepCopyObject().(crclient.Object)
+			apps.Sync(synced, object)
+		}
+
+		if err := printDiff(out, scheme, name, found, synced); err != nil {
+			return err
+		}
+	}
+
+	for _, object := range apps.PrunedObjects(executer) {
+		name, err := objectKey(scheme, object)
+		if err != nil {
+			return err
+		} else if desired[name] {
+			continue
+		}
+
+		found, err := getObject(ctx, client, scheme, object)
+		if err != nil {
+			return err
+		}
+
+		// only the objects owned by the live executer are deleted
+		if found == nil || live.UID == "" || !metav1.IsControlledBy(found, live) {
+			continue
+		}
+
+		if err := printDiff(out, scheme, name, found, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getObject returns the live object of the same kind and key, or nil if it doesn't exist
+func getObject(ctx context.Context, client crclient.Client, scheme *runtime.Scheme, object crclient.Object) (crclient.Object, error) {
+	gvk, err := apiutil.GVKForObject(object, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	live := found.(crclient.Object)
+	if err := client.Get(ctx, crclient.ObjectKeyFromObject(object), live); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting %s/%s/%s: %v", strings.ToLower(gvk.Kind), object.GetNamespace(), object.GetName(), err)
+	}
+	return live, nil
+}
+
+// objectKey identifies the object by its kind, namespace and name
+func objectKey(scheme *runtime.Scheme, object crclient.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(object, scheme)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.ToLower(gvk.Kind), object.GetNamespace(), object.GetName()), nil
+}
+
+// printDiff prints the unified diff of the objects, a nil one is missing
+func printDiff(out io.Writer, scheme *runtime.Scheme, name string, before, after crclient.Object) error {
+	var from, to string
+	var err error
+	if before != nil {
+		if from, err = toYAML(scheme, before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if to, err = toYAML(scheme, after); err != nil {
+			return err
+		}
+	}
+
+	content, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "live/" + name,
+		ToFile:   "rendered/" + name,
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(out, content)
+	return nil
+}
+
+// toUnstructured converts the object and drops the fields managed by the api-server
+func toUnstructured(object runtime.Object) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &unstructured.Unstructured{Object: content}
+	for _, fields := range noisyFields {
+		unstructured.RemoveNestedField(result.Object, fields...)
+	}
+
+	return result, nil
+}
+
+// toYAML prints the object with its kind and without the fields managed by the api-server
+func toYAML(scheme *runtime.Scheme, object crclient.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(object, scheme)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := toUnstructured(object)
+	if err != nil {
+		return "", err
+	}
+	result.SetGroupVersionKind(gvk)
+
+	content, err := yaml.Marshal(result.Object)
+	return string(content), err
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/config"
+	"github.com/mohammadne/sanjagh/controllers/apps"
+)
+
+// newManifestExecuter returns the executer with inline files, rbac and a sidecar
+func newManifestExecuter() *appsv1alpha1.Executer {
+	executer := &appsv1alpha1.Executer{
+		Spec: appsv1alpha1.ExecuterSpec{
+			Image:       "worker:v1",
+			Replication: 2,
+			Files:       map[string]string{"/etc/worker/a.yaml": "a: 1", "/etc/worker/b.yaml": "b: 2"},
+			RBAC:        &appsv1alpha1.RBAC{Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}},
+			Sidecars: []appsv1alpha1.Container{{
+				Name:  "shipper",
+				Image: "shipper:v1",
+				Env:   []corev1.EnvVar{{Name: "MODE", Value: "batch"}, {Name: "LEVEL", Value: "debug"}},
+			}},
+		},
+	}
+	executer.APIVersion, executer.Kind = appsv1alpha1.GroupVersion.String(), "Executer"
+	executer.Name, executer.Namespace = "worker", "default"
+	return executer
+}
+
+// diffHeaders returns the objects whose diffs are printed
+func diffHeaders(output string) []string {
+	var headers []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "--- live/") {
+			headers = append(headers, strings.TrimPrefix(line, "--- live/"))
+		}
+	}
+	return headers
+}
+
+func TestRender(t *testing.T) {
+	executer := newManifestExecuter()
+	executer.Namespace = ""
+	manifest, err := yaml.Marshal(executer)
+	require.NoError(t, err)
+
+	// the other objects are skipped
+	input := append([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n---\n"), manifest...)
+
+	cfg, err := config.Load(config.Sources{}, false)
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	render := Render{config: cfg, namespace: "staging"}
+	require.NoError(t, render.main(context.Background(), bytes.NewReader(input), out, nil))
+
+	var objects []string
+	for _, document := range strings.Split(out.String(), "---\n")[1:] {
+		object := &unstructured.Unstructured{}
+		require.NoError(t, yaml.Unmarshal([]byte(document), &object.Object))
+		objects = append(objects, object.GetKind()+" "+object.GetNamespace()+"/"+object.GetName())
+
+		require.Len(t, object.GetOwnerReferences(), 1)
+		assert.Equal(t, "Executer", object.GetOwnerReferences()[0].Kind)
+		assert.Empty(t, object.GetCreationTimestamp())
+		_, status := object.Object["status"]
+		assert.False(t, status)
+	}
+
+	assert.Equal(t, []string{
+		"ServiceAccount staging/worker",
+		"Role staging/worker",
+		"RoleBinding staging/worker",
+		"Deployment staging/worker",
+		"ConfigMap staging/worker-files",
+	}, objects)
+}
+
+func TestRenderDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		live    bool
+		change  func(executer *appsv1alpha1.Executer)
+		headers []string
+		lines   []string
+	}{
+		{
+			name:   "unchanged",
+			live:   true,
+			change: func(*appsv1alpha1.Executer) {},
+		},
+		{
+			name: "env variable removed",
+			live: true,
+			change: func(executer *appsv1alpha1.Executer) {
+				executer.Spec.Sidecars[0].Env = executer.Spec.Sidecars[0].Env[:1]
+			},
+			headers: []string{"deployment/default/worker"},
+			lines:   []string{"-        - name: LEVEL"},
+		},
+		{
+			name: "file removed",
+			live: true,
+			change: func(executer *appsv1alpha1.Executer) {
+				delete(executer.Spec.Files, "/etc/worker/b.yaml")
+			},
+			headers: []string{"deployment/default/worker", "configmap/default/worker-files"},
+			lines:   []string{"-  " + apps.FileKey("/etc/worker/b.yaml") + ": 'b: 2'"},
+		},
+		{
+			name: "rbac removed",
+			live: true,
+			change: func(executer *appsv1alpha1.Executer) {
+				executer.Spec.RBAC = nil
+			},
+			headers: []string{"deployment/default/worker", "serviceaccount/default/worker", "role/default/worker", "rolebinding/default/worker"},
+			lines:   []string{"-- apiGroups:"},
+		},
+		{
+			name:    "not created",
+			change:  func(*appsv1alpha1.Executer) {},
+			headers: []string{"serviceaccount/default/worker", "role/default/worker", "rolebinding/default/worker", "deployment/default/worker", "configmap/default/worker-files"},
+			lines:   []string{"+kind: Deployment"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, scheme := context.Background(), newScheme()
+			cfg, err := config.Load(config.Sources{}, false)
+			require.NoError(t, err)
+
+			client := fake.NewClientBuilder().WithScheme(scheme).Build()
+			if test.live {
+				live := newManifestExecuter()
+				live.UID = "worker-uid"
+				require.NoError(t, client.Create(ctx, live))
+
+				objects, err := apps.DesiredObjects(live, cfg.Controller, scheme)
+				require.NoError(t, err)
+
+				checksum, err := apps.ConfigChecksum(ctx, client, live)
+				require.NoError(t, err)
+				for _, object := range objects {
+					if deployment, ok := object.(*appsv1.Deployment); ok {
+						apps.SetConfigChecksum(deployment, checksum)
+					}
+					require.NoError(t, client.Create(ctx, object))
+				}
+			}
+
+			executer := newManifestExecuter()
+			test.change(executer)
+			manifest, err := yaml.Marshal(executer)
+			require.NoError(t, err)
+
+			out := &bytes.Buffer{}
+			render := Render{config: cfg, client: client, namespace: "default", diff: true}
+			require.NoError(t, render.main(ctx, bytes.NewReader(manifest), out, nil))
+
+			assert.Equal(t, test.headers, diffHeaders(out.String()))
+			for _, line := range test.lines {
+				assert.Contains(t, strings.Split(out.String(), "\n"), line)
+			}
+		})
+	}
+}
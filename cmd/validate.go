@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/config"
+	"github.com/mohammadne/sanjagh/webhook/validation"
+)
+
+type Validate struct {
+	config *config.Config
+}
+
+func NewValidate(cfg *config.Config) *cobra.Command {
+	validate := Validate{config: cfg}
+
+	cmd := &cobra.Command{
+		Use:   "validate [file | directory | -]...",
+		Short: "validate Executer manifests offline using the webhook validations",
+		Long: "Validate Executer manifests offline using the same validations of the webhook.\n" +
+			"YAML and JSON files are read from the given files and directories, or stdin if none or '-' is given.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validate.main(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout(), args)
+		},
+	}
+
+	return cmd
+}
+
+// manifest is a single object read from a source
+type manifest struct {
+	source string
+	raw    []byte
+}
+
+func (cmd *Validate) main(ctx context.Context, stdin io.Reader, out io.Writer, paths []string) error {
+	manifests, err := readManifests(stdin, paths)
+	if err != nil {
+		return err
+	}
+
+	client := fake.NewClientBuilder().WithScheme(newScheme()).Build()
+	validation := validation.NewValidation(cmd.config.Webhook.Validation, client, zap.NewNop())
+
+	var failed int
+	for _, manifest := range manifests {
+		object := metav1.PartialObjectMetadata{}
+		if err := utilyaml.Unmarshal(manifest.raw, &object); err != nil {
+			return fmt.Errorf("%s: %v", manifest.source, err)
+		}
+
+		gvk := object.GroupVersionKind()
+		if gvk.Group != appsv1alpha1.GroupVersion.Group || gvk.Kind != "Executer" {
+			continue
+		}
+
+		review := &admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{
+				UID:       uuid.NewUUID(),
+				Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+				Resource:  metav1.GroupVersionResource{Group: gvk.Group, Version: gvk.Version, Resource: "executers"},
+				Name:      object.Name,
+				Namespace: object.Namespace,
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: manifest.raw},
+			},
+		}
+
+		name := fmt.Sprintf("%s: %s %s", manifest.source, gvk.Kind, objectName(&object))
+		if err := validation.Validate(ctx, review); err != nil {
+			failed++
+			fmt.Fprintf(out, "%s: error\n  - %v\n", name, err)
+			continue
+		}
+
+		if review.Response.Allowed {
+			fmt.Fprintf(out, "%s: valid\n", name)
+			continue
+		}
+
+		failed++
+		fmt.Fprintf(out, "%s: invalid\n", name)
+		for _, reason := range reasons(review.Response.Result) {
+			fmt.Fprintf(out, "  - %s\n", reason)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d executer(s) failed validation", failed)
+	}
+
+	return nil
+}
+
+func objectName(object *metav1.PartialObjectMetadata) string {
+	if object.Namespace == "" {
+		return object.Name
+	}
+	return object.Namespace + "/" + object.Name
+}
+
+func reasons(status *metav1.Status) []string {
+	if status == nil {
+		return nil
+	}
+
+	if status.Details != nil && len(status.Details.Causes) > 0 {
+		reasons := make([]string, 0, len(status.Details.Causes))
+		for _, cause := range status.Details.Causes {
+			reasons = append(reasons, cause.Message)
+		}
+		return reasons
+	}
+
+	return []string{status.Message}
+}
+
+var manifestExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// readManifests reads all the objects of the given paths, stdin is used for '-' or no paths
+func readManifests(stdin io.Reader, paths []string) ([]manifest, error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	var manifests []manifest
+	for _, path := range paths {
+		if path == "-" {
+			objects, err := decodeManifests("<stdin>", stdin)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, objects...)
+			continue
+		}
+
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			// the extension is only checked for the files found inside the directories
+			if entry.IsDir() || (file != path && !manifestExtensions[strings.ToLower(filepath.Ext(file))]) {
+				return nil
+			}
+
+			reader, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer reader.Close()
+
+			objects, err := decodeManifests(file, reader)
+			if err != nil {
+				return err
+			}
+			manifests = append(manifests, objects...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return manifests, nil
+}
+
+// decodeManifests splits the yaml documents or json objects of the reader
+func decodeManifests(source string, reader io.Reader) ([]manifest, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(reader, 4096)
+
+	var manifests []manifest
+	for index := 0; ; index++ {
+		object := runtime.RawExtension{}
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return manifests, nil
+			}
+			return nil, fmt.Errorf("%s: error decoding document %d: %v", source, index, err)
+		}
+
+		if len(object.Raw) == 0 || string(object.Raw) == "null" {
+			continue
+		}
+
+		manifests = append(manifests, manifest{source: source, raw: object.Raw})
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mohammadne/sanjagh/config"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output []string
+		err    string
+	}{
+		{
+			name: "multiple yaml documents",
+			input: `
+apiVersion: apps.mohammadne.me/v1alpha1
+kind: Executer
+metadata:
+  name: good
+  namespace: default
+spec:
+  image: nginx:1.25
+  replication: 2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+---
+apiVersion: apps.mohammadne.me/v1alpha1
+kind: Executer
+metadata:
+  name: bad
+spec:
+  image: nginx:1.25
+  replication: 9
+`,
+			output: []string{
+				"<stdin>: Executer default/good: valid",
+				"<stdin>: Executer bad: invalid",
+				"  - Replication exceeds the maximum value: '5'",
+			},
+			err: "1 executer(s) failed validation",
+		},
+		{
+			name: "json objects",
+			input: `{"apiVersion": "apps.mohammadne.me/v1alpha1", "kind": "Executer", "metadata": {"name": "first"}, "spec": {"image": "nginx:1.25", "replication": 1}}
+{"apiVersion": "apps.mohammadne.me/v1alpha1", "kind": "Executer", "metadata": {"name": "second"}, "spec": {"image": "nginx:1.25", "replication": 3}}`,
+			output: []string{
+				"<stdin>: Executer first: invalid",
+				"  - Replication is lower than the minimum value: '2'",
+				"<stdin>: Executer second: valid",
+			},
+			err: "1 executer(s) failed validation",
+		},
+		{
+			name: "being deleted",
+			input: `
+apiVersion: apps.mohammadne.me/v1alpha1
+kind: Executer
+metadata:
+  name: leaving
+  namespace: default
+  deletionTimestamp: "2023-01-01T00:00:00Z"
+spec:
+  image: nginx:1.25
+  replication: 9
+`,
+			output: []string{"<stdin>: Executer default/leaving: valid"},
+		},
+		{
+			name: "no executers",
+			input: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+---
+apiVersion: apps.mohammadne.me/v1alpha1
+kind: Other
+metadata:
+  name: worker
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := config.Load(config.Sources{}, false)
+			require.NoError(t, err)
+
+			out := &bytes.Buffer{}
+			validate := Validate{config: cfg}
+			err = validate.main(context.Background(), strings.NewReader(test.input), out, nil)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+
+			var output []string
+			if content := strings.TrimSuffix(out.String(), "\n"); content != "" {
+				output = strings.Split(content, "\n")
+			}
+			assert.Equal(t, test.output, output)
+		})
+	}
+}
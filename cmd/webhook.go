@@ -1,17 +1,31 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
 	"syscall"
 
+	"github.com/go-logr/zapr"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	crclient "sigs.k8s.io/controller-runtime/pkg/client"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	"github.com/mohammadne/sanjagh/config"
 	"github.com/mohammadne/sanjagh/pkg/k8s"
 	"github.com/mohammadne/sanjagh/pkg/logger"
+	"github.com/mohammadne/sanjagh/pkg/tracing"
+	"github.com/mohammadne/sanjagh/webhook/audit"
 	"github.com/mohammadne/sanjagh/webhook/server"
 	"github.com/mohammadne/sanjagh/webhook/validation"
 )
@@ -27,8 +41,9 @@ func NewWebhook(cfg *config.Config) *cobra.Command {
 	webhook := Webhook{config: cfg}
 
 	cmd := &cobra.Command{
-		Use:   "webhook",
-		Short: "run webhook server",
+		Use:         "webhook",
+		Short:       "run webhook server",
+		Annotations: map[string]string{PrintConfig: "true"},
 		Run: func(_ *cobra.Command, _ []string) {
 			webhook.main()
 		},
@@ -42,30 +57,171 @@ func NewWebhook(cfg *config.Config) *cobra.Command {
 }
 
 func (cmd *Webhook) main() {
-	logger := logger.NewZap(cmd.config.Logger)
+	lg := logger.NewZap(cmd.config.Logger)
+
+	// route the logs of controller-runtime and client-go into the configured logger
+	ctrl.SetLogger(zapr.NewLogger(lg))
+	klog.SetLogger(zapr.NewLogger(lg))
+
+	shutdown, err := tracing.New(cmd.config.Tracing)
+	if err != nil {
+		lg.Fatal("Unable to set up tracing", zap.Error(err))
+	}
+	defer shutdown(context.Background())
 
 	kubeConfig, err := k8s.KubeConfig(cmd.kubeconfig)
 	if err != nil {
-		logger.Fatal("Unable to create kubernetes configuration", zap.Error(err))
+		lg.Fatal("Unable to create kubernetes configuration", zap.Error(err))
+	}
+
+	if cmd.config.Tracing.Enabled {
+		kubeConfig.Wrap(tracing.Transport)
+	}
+
+	if cmd.config.Webhook.Server.Backend == server.BackendControllerRuntime {
+		cmd.serveControllerRuntime(kubeConfig, lg)
+		return
 	}
 
 	client, err := k8s.NewCachedClient(kubeConfig, indexer)
 	if err != nil {
-		logger.Fatal("Couldn't create cached client", zap.Error(err))
+		lg.Fatal("Couldn't create cached client", zap.Error(err))
+	}
+
+	server, reload, err := newServer(cmd.config, client, lg)
+	if err != nil {
+		lg.Fatal("Unable to set up webhook server", zap.Error(err))
 	}
 
-	validation := validation.NewValidation(cmd.config.Webhook.Validation, client)
+	if err := prometheus.Register(config.Reloads); err != nil {
+		lg.Fatal("Unable to register config metrics", zap.Error(err))
+	} else if err := registerWebhookMetrics(prometheus.DefaultRegisterer); err != nil {
+		lg.Fatal("Unable to register webhook metrics", zap.Error(err))
+	}
 
 	trap := make(chan os.Signal, 1)
 	signal.Notify(trap, syscall.SIGINT, syscall.SIGTERM)
 
-	server.New(cmd.config.Webhook.Server, logger, validation).
-		Serve(cmd.managementPort, cmd.masterPort)
+	server.Serve(cmd.managementPort, cmd.masterPort)
+
+	watcher := config.NewWatcher(cmd.config, lg)
+	watcher.OnChange(func(cfg *config.Config) {
+		logger.Reload(lg, cfg.Logger)
+		reload(cfg)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := watcher.Start(ctx); err != nil {
+			lg.Error("Unable to watch configuration", zap.Error(err))
+		}
+	}()
 
 	// Keep this at the bottom of the main function
 	field := zap.String("signal trap", (<-trap).String())
-	logger.Info("exiting by receiving a unix signal", field)
+	lg.Info("exiting by receiving a unix signal", field)
+}
+
+// serveControllerRuntime serves the webhook by a manager without controllers, sharing its cache and webhook server
+func (cmd *Webhook) serveControllerRuntime(kubeConfig *rest.Config, lg *zap.Logger) {
+	manager, err := ctrl.NewManager(kubeConfig, ctrl.Options{
+		Scheme:                 newScheme(),
+		MetricsBindAddress:     fmt.Sprintf(":%d", cmd.managementPort),
+		HealthProbeBindAddress: "0",
+		WebhookServer:          admissionServer(cmd.config.Webhook.Server, cmd.masterPort),
+	})
+	if err != nil {
+		lg.Fatal("Unable to start manager", zap.Error(err))
+	}
+
+	server, reload, err := newServer(cmd.config, manager.GetClient(), lg)
+	if err != nil {
+		lg.Fatal("Unable to set up webhook server", zap.Error(err))
+	}
+	server.Register(manager.GetWebhookServer())
+
+	// the management endpoints are served next to the metrics, the same as the management app
+	for path, handler := range server.ManagementHandlers() {
+		if err := manager.AddMetricsExtraHandler(path, handler); err != nil {
+			lg.Fatal("Unable to set up management handler", zap.String("path", path), zap.Error(err))
+		}
+	}
+
+	if err := crmetrics.Registry.Register(config.Reloads); err != nil {
+		lg.Fatal("Unable to register config metrics", zap.Error(err))
+	} else if err := registerWebhookMetrics(crmetrics.Registry); err != nil {
+		lg.Fatal("Unable to register webhook metrics", zap.Error(err))
+	}
+
+	watcher := config.NewWatcher(cmd.config, lg)
+	watcher.OnChange(func(cfg *config.Config) {
+		logger.Reload(lg, cfg.Logger)
+		reload(cfg)
+	})
+
+	if err := manager.Add(watcher); err != nil {
+		lg.Fatal("Unable to set up config watcher", zap.Error(err))
+	}
+
+	lg.Info("Starting webhook with controller-runtime backend")
+	if err := manager.Start(ctrl.SetupSignalHandler()); err != nil {
+		lg.Info("Problem running manager", zap.Error(err))
+	}
+}
+
+// admissionServer is the webhook server of controller-runtime, using the TLS files of the config
+func admissionServer(cfg *server.Config, port int) *webhook.Server {
+	return &webhook.Server{
+		Port:     port,
+		CertDir:  filepath.Dir(cfg.TLS.Certificate),
+		CertName: filepath.Base(cfg.TLS.Certificate),
+		KeyName:  filepath.Base(cfg.TLS.PrivateKey),
+	}
 }
 
 // indexer adds indexers for given cached client
 func indexer(cache cache.Cache) {}
+
+// registerWebhookMetrics registers the metrics of the webhook on the registry served by its process,
+// shared by the webhook and all-in-one commands
+func registerWebhookMetrics(registerer prometheus.Registerer) error {
+	return registerer.Register(audit.DroppedRecords)
+}
+
+// newServer creates the webhook server, the returned function applies a new configuration to it
+func newServer(cfg *config.Config, client crclient.Reader, lg *zap.Logger) (*server.Server, func(*config.Config), error) {
+	if err := cfg.Webhook.Server.ValidateFiles(); err != nil {
+		return nil, nil, fmt.Errorf("invalid webhook server configuration: %v", err)
+	}
+
+	validation := validation.NewValidation(cfg.Webhook.Validation, client, lg)
+
+	auditor, err := audit.New(cfg.Webhook.Audit, lg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating auditor: %v", err)
+	}
+
+	server := server.New(cfg.Webhook.Server, lg, validation, auditor)
+
+	auditConfig := cfg.Webhook.Audit
+	reload := func(cfg *config.Config) {
+		validation.Reload(cfg.Webhook.Validation)
+
+		// the auditor is only recreated when its configuration changes, keeping the sink and its queued records
+		if reflect.DeepEqual(auditConfig, cfg.Webhook.Audit) {
+			return
+		}
+
+		auditor, err := audit.New(cfg.Webhook.Audit, lg)
+		if err != nil {
+			lg.Error("Unable to create auditor from the new configuration", zap.Error(err))
+			return
+		}
+		server.SetAuditor(auditor)
+		auditConfig = cfg.Webhook.Audit
+	}
+
+	return server, reload, nil
+}
@@ -1,15 +1,62 @@
 package config
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mohammadne/sanjagh/controllers/apps"
 	"github.com/mohammadne/sanjagh/pkg/logger"
+	"github.com/mohammadne/sanjagh/pkg/tracing"
+	webhookAudit "github.com/mohammadne/sanjagh/webhook/audit"
 	webhookServer "github.com/mohammadne/sanjagh/webhook/server"
 	webhookValidation "github.com/mohammadne/sanjagh/webhook/validation/config"
 )
 
 type Config struct {
-	Logger  *logger.Config `koanf:"logger"`
-	Webhook struct {
+	Logger     *logger.Config  `koanf:"logger"`
+	Tracing    *tracing.Config `koanf:"tracing"`
+	Controller *apps.Config    `koanf:"controller"`
+	Webhook    struct {
 		Server     *webhookServer.Config     `koanf:"server"`
 		Validation *webhookValidation.Config `koanf:"validation"`
+		Audit      *webhookAudit.Config      `koanf:"audit"`
 	} `koanf:"webhook"`
+
+	// sources are kept to reload the configuration the same way
+	sources Sources
+}
+
+// Validate checks the values of all the sections and reports all of their errors
+func (c *Config) Validate() error {
+	sections := []struct {
+		name    string
+		section interface{ Validate() error }
+	}{
+		{"logger", c.Logger},
+		{"tracing", c.Tracing},
+		{"controller", c.Controller},
+		{"webhook.server", c.Webhook.Server},
+		{"webhook.validation", c.Webhook.Validation},
+		{"webhook.audit", c.Webhook.Audit},
+	}
+
+	var errs []string
+	for _, s := range sections {
+		// the sections set to null in a source aren't filled by the default values
+		if reflect.ValueOf(s.section).IsNil() {
+			errs = append(errs, fmt.Sprintf("%s: section is missing", s.name))
+			continue
+		}
+
+		if err := s.section.Validate(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", s.name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(errs, "\n  "))
+	}
+
+	return nil
 }
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaultFile(t *testing.T) {
+	cfg, err := LoadFile(writeFile(t, "---\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), cfg.Webhook.Validation.Replication.Maximum)
+	assert.NotEmpty(t, cfg.Webhook.Validation.RBAC.Ceiling)
+	assert.Equal(t, cfg.Controller.RBAC.Ceiling, cfg.Webhook.Validation.RBAC.Ceiling)
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	content := `
+logger:
+  level: loud
+webhook:
+  server:
+    tls:
+      certificate: ""
+  validation:
+    replication:
+      minimum: 7
+`
+	_, err := LoadFile(writeFile(t, content))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "logger: invalid level")
+	assert.Contains(t, err.Error(), "webhook.server: TLS Certificate or PrivateKey is empty")
+	assert.Contains(t, err.Error(), "webhook.validation: replication minimum (7) is greater than its maximum (5)")
+}
+
+func TestLoadNullSections(t *testing.T) {
+	content := `
+controller: null
+webhook:
+  audit: null
+`
+	_, err := LoadFile(writeFile(t, content))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "controller: section is missing")
+	assert.Contains(t, err.Error(), "webhook.audit: section is missing")
+	assert.NotContains(t, err.Error(), "logger")
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema()
+	require.NoError(t, err)
+
+	logger := schema["properties"].(map[string]any)["logger"].(map[string]any)
+	level := logger["properties"].(map[string]any)["level"].(map[string]any)
+	assert.Equal(t, "string", level["type"])
+	assert.Equal(t, "info", level["default"])
+
+	sampling := logger["properties"].(map[string]any)["sampling"].(map[string]any)
+	tick := sampling["properties"].(map[string]any)["tick"].(map[string]any)
+	assert.Equal(t, "duration", tick["format"])
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	first := writeFile(t, "logger:\n  level: warn\n  encoding: json\n")
+	second := filepath.Join(t.TempDir(), "second.yaml")
+	require.NoError(t, os.WriteFile(second, []byte("logger:\n  level: error\nwebhook:\n  validation:\n    replication:\n      maximum: 7\n"), 0o600))
+
+	t.Setenv("SANJAGH__WEBHOOK__VALIDATION__REPLICATION__MAXIMUM", "8")
+
+	sources := Sources{Files: []string{first, second}, Overrides: []string{"webhook.validation.replication.maximum=9"}}
+	cfg, err := Load(sources, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "json", cfg.Logger.Encoding)
+	assert.Equal(t, "error", cfg.Logger.Level)
+	assert.Equal(t, int32(9), cfg.Webhook.Validation.Replication.Maximum)
+
+	sources.Overrides = nil
+	cfg, err = Load(sources, false)
+	require.NoError(t, err)
+	assert.Equal(t, int32(8), cfg.Webhook.Validation.Replication.Maximum)
+
+	_, err = Load(Sources{Overrides: []string{"invalid"}}, false)
+	assert.Error(t, err)
+}
+
+func TestRedact(t *testing.T) {
+	k, err := load(Sources{})
+	require.NoError(t, err)
+	require.NoError(t, redact(k))
+
+	assert.Equal(t, redacted, k.String("webhook.server.tls.certificate"))
+	assert.Equal(t, redacted, k.String("webhook.server.tls.private_key"))
+	assert.Equal(t, redacted, k.String("webhook.audit.sink.url"))
+	assert.Equal(t, "info", k.String("logger.level"))
+}
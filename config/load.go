@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"strings"
 
-	"github.com/davecgh/go-spew/spew"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/rawbytes"
@@ -23,12 +23,59 @@ const (
 	bottomTemplate = "======================================================"
 )
 
-func Load(print bool) *Config {
+// Sources are the user provided sources of the configuration, the precedence
+// is Overrides > environment variables > Files (in the given order) > defaults.
+type Sources struct {
+	Files     []string
+	Overrides []string // key=value pairs
+}
+
+func Load(sources Sources, print bool) (*Config, error) {
+	k, err := load(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := unmarshal(k)
+	if err != nil {
+		return nil, err
+	}
+	config.sources = sources
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if print {
+		// pretty print loaded configuration using provided template
+		if err := redact(k); err != nil {
+			return nil, err
+		}
+
+		content, err := k.Marshal(yaml.Parser())
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling config: %v", err)
+		}
+
+		log.Printf("%s\n%s%s\n", upTemplate, content, bottomTemplate)
+	}
+
+	return config, nil
+}
+
+func load(sources Sources) (*koanf.Koanf, error) {
 	k := koanf.New(delimiter)
 
 	// load default configuration from defaults file
 	if err := loadDefaults(k); err != nil {
-		log.Fatalf("Error loading default values: \n%v", err)
+		return nil, fmt.Errorf("Error loading default values: \n%v", err)
+	}
+
+	// load config from the given files
+	for _, file := range sources.files() {
+		if err := loadFile(k, file); err != nil {
+			return nil, err
+		}
 	}
 
 	// load config from environment variables
@@ -36,23 +83,48 @@ func Load(print bool) *Config {
 		log.Printf("error loading environment variables: %v", err)
 	}
 
-	// load config from configmap
-	if err := loadConfigmap(k); err != nil {
-		log.Fatalf("Error loading from configmap: \n%v", err)
+	// load config from the overrides
+	if err := loadOverrides(k, sources.Overrides); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// LoadFile loads the configuration from the given file on top of the default values and validates it
+func LoadFile(path string) (*Config, error) {
+	k := koanf.New(delimiter)
+
+	if err := loadDefaults(k); err != nil {
+		return nil, err
+	}
+
+	if err := loadFile(k, path); err != nil {
+		return nil, err
 	}
 
+	config, err := unmarshal(k)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, config.Validate()
+}
+
+func unmarshal(k *koanf.Koanf) (*Config, error) {
 	config := Config{}
 	var tag = koanf.UnmarshalConf{Tag: tagName}
 	if err := k.UnmarshalWithConf("", &config, tag); err != nil {
-		log.Fatalf("error unmarshalling config: %v", err)
+		return nil, fmt.Errorf("error unmarshalling config: %v", err)
 	}
 
-	if print {
-		// pretty print loaded configuration using provided template
-		log.Printf("%s\n%v\n%s\n", upTemplate, spew.Sdump(config), bottomTemplate)
+	// the webhook rejects the executers beyond the rbac ceiling of the controller,
+	// the missing sections are reported by the validation
+	if config.Controller != nil && config.Webhook.Validation != nil {
+		config.Webhook.Validation.RBAC.Ceiling = config.Controller.RBAC.Ceiling
 	}
 
-	return &config
+	return &config, nil
 }
 
 //go:embed defaults.yml
@@ -89,20 +161,84 @@ func loadEnv(k *koanf.Koanf) error {
 	return nil
 }
 
-// loadConfigmap loads the configuration from K8S Conficonfigmap
-func loadConfigmap(k *koanf.Koanf) error {
-	if os.Getenv("RUNNING_INSIDE_POD") == "" {
-		return nil
+// configmapPath is where the K8S configmap of the operator is mounted,
+// it's used when no file is given and the operator is running inside the pod.
+const configmapPath = "/tmp/operator/config.yaml"
+
+func runningInsidePod() bool {
+	return os.Getenv("RUNNING_INSIDE_POD") != ""
+}
+
+func (s Sources) files() []string {
+	if len(s.Files) == 0 && runningInsidePod() {
+		return []string{configmapPath}
 	}
+	return s.Files
+}
 
-	cm, err := os.ReadFile("/tmp/operator/config.yaml")
+// loadFile loads the configuration from the given yaml file
+func loadFile(k *koanf.Koanf, path string) error {
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("Error reading currnet namespace: %v", err)
+		return fmt.Errorf("Error reading config file %s: %v", path, err)
 	}
 
-	if err := k.Load(rawbytes.Provider(cm), yaml.Parser()); err != nil {
-		return fmt.Errorf("Error loading values: %s", err)
+	if err := k.Load(rawbytes.Provider(content), yaml.Parser()); err != nil {
+		return fmt.Errorf("Error loading config file %s: %s", path, err)
 	}
 
 	return nil
 }
+
+// loadOverrides sets the key=value pairs on top of the loaded configuration
+func loadOverrides(k *koanf.Koanf, overrides []string) error {
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid override %q, it should be in key=value format", override)
+		}
+
+		if err := k.Set(key, value); err != nil {
+			return fmt.Errorf("error setting override %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+const redacted = "[REDACTED]"
+
+// redact masks the values of the keys which are tagged as sensitive in the Config
+func redact(k *koanf.Koanf) error {
+	for _, key := range sensitiveKeys(reflect.TypeOf(Config{}), "") {
+		if k.Exists(key) && k.String(key) != "" {
+			if err := k.Set(key, redacted); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func sensitiveKeys(t reflect.Type, parent string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var keys []string
+	if t.Kind() != reflect.Struct || t == durationType {
+		return keys
+	}
+
+	walkFields(t, func(field reflect.StructField, name string) {
+		key := joinKey(parent, name)
+		if field.Tag.Get("sensitive") == "true" {
+			keys = append(keys, key)
+			return
+		}
+		keys = append(keys, sensitiveKeys(field.Type, key)...)
+	})
+
+	return keys
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/knadh/koanf/v2"
+)
+
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Schema generates the JSON Schema of the configuration from the koanf
+// tags of the Config, the default values are taken from the defaults file.
+func Schema() (map[string]any, error) {
+	k := koanf.New(delimiter)
+	if err := loadDefaults(k); err != nil {
+		return nil, err
+	}
+
+	schema := schemaOf(reflect.TypeOf(Config{}), "", k)
+	schema["$schema"] = schemaDraft
+	schema["title"] = "Sanjagh configuration"
+
+	return schema, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func schemaOf(t reflect.Type, key string, defaults *koanf.Koanf) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	schema := make(map[string]any)
+	if key != "" && defaults.Exists(key) && t.Kind() != reflect.Struct {
+		schema["default"] = defaults.Get(key)
+	}
+
+	switch {
+	case t == durationType:
+		schema["type"] = "string"
+		schema["format"] = "duration"
+	case t.Kind() == reflect.Bool:
+		schema["type"] = "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema["type"] = "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema["type"] = "number"
+	case t.Kind() == reflect.String:
+		schema["type"] = "string"
+	case t.Kind() == reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = schemaOf(t.Elem(), "", defaults)
+	case t.Kind() == reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = schemaOf(t.Elem(), "", defaults)
+	case t.Kind() == reflect.Struct:
+		properties := make(map[string]any)
+		walkFields(t, func(field reflect.StructField, name string) {
+			properties[name] = schemaOf(field.Type, joinKey(key, name), defaults)
+		})
+
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+	}
+
+	return schema
+}
+
+// walkFields calls fn for every field of the struct which has a koanf tag
+func walkFields(t reflect.Type, fn func(reflect.StructField, string)) {
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		name := strings.Split(field.Tag.Get(tagName), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fn(field, name)
+	}
+}
+
+func joinKey(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + delimiter + name
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Reloads counts the reloads of the configuration partitioned by their result,
+// it should be registered on the registry of the running command.
+var Reloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "sanjagh",
+	Subsystem: "config",
+	Name:      "reloads_total",
+	Help:      "Total number of configuration reloads partitioned by their result.",
+}, []string{"result"})
+
+// Watcher reloads the configuration whenever one of its files changes
+// and passes the valid ones to the registered handlers.
+type Watcher struct {
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	current  *Config
+	handlers []func(*Config)
+}
+
+func NewWatcher(cfg *Config, lg *zap.Logger) *Watcher {
+	return &Watcher{current: cfg, logger: lg.Named("config-watcher")}
+}
+
+// OnChange registers a handler to be called with every newly applied configuration
+func (w *Watcher) OnChange(handler func(*Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.handlers = append(w.handlers, handler)
+}
+
+// Current returns the last valid configuration
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.current
+}
+
+// NeedLeaderElection makes the watcher run on every replica of the controller manager
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start watches the configuration files until the context is done, it returns immediately if there is no file
+func (w *Watcher) Start(ctx context.Context) error {
+	sources := w.Current().sources
+	files := sources.files()
+	if len(files) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// configmaps are mounted as symlinks which get swapped on updates, so the directories are watched
+	watched := make(map[string]bool)
+	for _, file := range files {
+		directory, name := filepath.Split(filepath.Clean(file))
+		watched[filepath.Join(directory, name)] = true
+		watched[filepath.Join(directory, "..data")] = true
+		if err := watcher.Add(filepath.Clean(directory)); err != nil {
+			return err
+		}
+	}
+
+	w.logger.Info("Watching configuration for changes", zap.Strings("files", files))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-watcher.Events:
+			if watched[filepath.Clean(event.Name)] {
+				w.reload(sources)
+			}
+		case err := <-watcher.Errors:
+			w.logger.Error("Error watching configuration", zap.Error(err))
+		}
+	}
+}
+
+func (w *Watcher) reload(sources Sources) {
+	config, err := Load(sources, false)
+	if err != nil {
+		Reloads.WithLabelValues("failure").Inc()
+		w.logger.Error("Rejected the new configuration, keeping the last valid one", zap.Error(err))
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.current = config
+	for _, handler := range w.handlers {
+		handler(config)
+	}
+
+	Reloads.WithLabelValues("success").Inc()
+	w.logger.Info("Reloaded the configuration")
+}
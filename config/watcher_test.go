@@ -0,0 +1,70 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// replaceFile swaps the file at once like the kubelet updates the mounted ConfigMaps, so no partial content is read
+func replaceFile(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path+".tmp", []byte(content), 0o600))
+	require.NoError(t, os.Rename(path+".tmp", path))
+}
+
+func TestWatcherReload(t *testing.T) {
+	path := writeFile(t, "---\n")
+	cfg, err := Load(Sources{Files: []string{path}}, false)
+	require.NoError(t, err)
+	require.True(t, cfg.Controller.Rollback.Enabled)
+	require.False(t, cfg.Webhook.Audit.Enabled)
+
+	watcher := NewWatcher(cfg, zap.NewNop())
+	reloaded := make(chan *Config, 10)
+	watcher.OnChange(func(cfg *Config) { reloaded <- cfg })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { assert.NoError(t, watcher.Start(ctx)) }()
+
+	// the watcher is started once a write of the same content is noticed
+	require.Eventually(t, func() bool {
+		replaceFile(t, path, "---\n")
+		select {
+		case <-reloaded:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// the switches of the features are applied live
+	content := "controller:\n  rollback:\n    enabled: false\nwebhook:\n  audit:\n    enabled: true\n"
+	replaceFile(t, path, content)
+
+	var current *Config
+	require.Eventually(t, func() bool {
+		select {
+		case current = <-reloaded:
+		default:
+		}
+		return current != nil && current.Webhook.Audit.Enabled
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, current.Controller.Rollback.Enabled)
+	assert.True(t, watcher.Current().Webhook.Audit.Enabled)
+
+	// the invalid configurations are rejected, keeping the last valid one
+	failures := testutil.ToFloat64(Reloads.WithLabelValues("failure"))
+	replaceFile(t, path, "logger:\n  level: loud\n")
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(Reloads.WithLabelValues("failure")) > failures
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "info", watcher.Current().Logger.Level)
+	assert.True(t, watcher.Current().Webhook.Audit.Enabled)
+}
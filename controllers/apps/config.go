@@ -0,0 +1,55 @@
+package apps
+
+import (
+	"fmt"
+
+	"github.com/mohammadne/sanjagh/pkg/rbac"
+)
+
+type Config struct {
+	Security struct {
+		// Defaults are applied to the security contexts of the pods, unless they're set by the executer explicitly
+		Defaults struct {
+			RunAsNonRoot           bool `koanf:"run_as_non_root"`
+			ReadOnlyRootFilesystem bool `koanf:"read_only_root_filesystem"`
+			DropAllCapabilities    bool `koanf:"drop_all_capabilities"`
+			SeccompRuntimeDefault  bool `koanf:"seccomp_runtime_default"`
+		} `koanf:"defaults"`
+	} `koanf:"security"`
+
+	// Rollback reverts the failed rollouts of the executers to their last good pod template
+	Rollback struct {
+		Enabled bool `koanf:"enabled"`
+		// RestartThreshold is the restarts of a container of the new pods which fail the rollout, disabled if 0
+		RestartThreshold int32 `koanf:"restart_threshold"`
+	} `koanf:"rollback"`
+
+	RBAC struct {
+		// Ceiling are the rules the roles of the executers can grant at most, no rule is allowed if it's empty,
+		// the manager must hold them itself as it isn't allowed to escalate
+		Ceiling []rbac.Rule `koanf:"ceiling"`
+	} `koanf:"rbac"`
+
+	Revisions struct {
+		// HistoryLimit is the number of the old revisions of each executer to keep for rollbacks
+		HistoryLimit int32 `koanf:"history_limit"`
+	} `koanf:"revisions"`
+}
+
+func (c *Config) Validate() error {
+	if c.Rollback.RestartThreshold < 0 {
+		return fmt.Errorf("rollback restart threshold is negative: %d", c.Rollback.RestartThreshold)
+	}
+
+	if c.Revisions.HistoryLimit < 0 {
+		return fmt.Errorf("revisions history limit is negative: %d", c.Revisions.HistoryLimit)
+	}
+
+	for index, rule := range c.RBAC.Ceiling {
+		if len(rule.APIGroups) == 0 || len(rule.Resources) == 0 || len(rule.Verbs) == 0 {
+			return fmt.Errorf("rbac ceiling rule %d must have api groups, resources and verbs", index)
+		}
+	}
+
+	return nil
+}
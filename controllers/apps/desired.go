@@ -0,0 +1,196 @@
+package apps
+
+import (
+	"reflect"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// DesiredObjects returns the objects owned by the executer, exactly as the controller creates them
+func DesiredObjects(executer *appsv1alpha1.Executer, cfg *Config, scheme *runtime.Scheme) ([]client.Object, error) {
+	objects := append(rbacObjects(executer), deploymentTemplate(executer, cfg))
+	for _, claim := range claimTemplates(executer) {
+		objects = append(objects, claim)
+	}
+	if configMap := filesConfigMap(executer); configMap != nil {
+		objects = append(objects, configMap)
+	}
+	if budget := disruptionBudget(executer); budget != nil {
+		objects = append(objects, budget)
+	}
+
+	for _, object := range objects {
+		if err := ctrl.SetControllerReference(executer, object, scheme); err != nil {
+			return nil, err
+		}
+	}
+
+	return objects, nil
+}
+
+// PrunedObjects returns the objects which the controller deletes once the executer doesn't need them,
+// only their names and namespaces are set
+func PrunedObjects(executer *appsv1alpha1.Executer) []client.Object {
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: executer.Namespace}
+	}
+
+	return []client.Object{
+		&corev1.ServiceAccount{ObjectMeta: meta(executer.Name)},
+		&rbacv1.Role{ObjectMeta: meta(executer.Name)},
+		&rbacv1.RoleBinding{ObjectMeta: meta(executer.Name)},
+		&corev1.ConfigMap{ObjectMeta: meta(FilesConfigMapName(executer))},
+		&policyv1.PodDisruptionBudget{ObjectMeta: meta(executer.Name)},
+	}
+}
+
+// Sync copies the fields of the desired object which the controller keeps in sync into the found one,
+// and reports whether it's changed. The other fields, like the ones defaulted by the api-server, are kept untouched.
+func Sync(found, desired client.Object) bool {
+	switch found := found.(type) {
+	case *appsv1.Deployment:
+		desired := desired.(*appsv1.Deployment)
+		changed := !equality.Semantic.DeepEqual(found.Spec.Replicas, desired.Spec.Replicas)
+		found.Spec.Replicas = desired.Spec.Replicas
+		if templateChanged(found, desired) || strategyChanged(found, desired) {
+			found.Spec.Strategy = desired.Spec.Strategy
+			copyTemplate(&found.Spec.Template, &desired.Spec.Template)
+			changed = true
+		}
+		return changed
+
+	case *corev1.ConfigMap:
+		data := desired.(*corev1.ConfigMap).Data
+		if reflect.DeepEqual(found.Data, data) && len(found.BinaryData) == 0 {
+			return false
+		}
+		found.Data, found.BinaryData = data, nil
+		return true
+
+	case *policyv1.PodDisruptionBudget:
+		spec := desired.(*policyv1.PodDisruptionBudget).Spec
+		if equality.Semantic.DeepEqual(found.Spec.MinAvailable, spec.MinAvailable) &&
+			equality.Semantic.DeepEqual(found.Spec.MaxUnavailable, spec.MaxUnavailable) {
+			return false
+		}
+		found.Spec.MinAvailable, found.Spec.MaxUnavailable = spec.MinAvailable, spec.MaxUnavailable
+		return true
+
+	case *rbacv1.Role:
+		rules := desired.(*rbacv1.Role).Rules
+		if equality.Semantic.DeepEqual(found.Rules, rules) {
+			return false
+		}
+		found.Rules = rules
+		return true
+
+	case *rbacv1.RoleBinding:
+		// the role reference of the binding is immutable and always the same
+		subjects := desired.(*rbacv1.RoleBinding).Subjects
+		if equality.Semantic.DeepEqual(found.Subjects, subjects) {
+			return false
+		}
+		found.Subjects = subjects
+		return true
+	}
+
+	// the service account and the claims are only created
+	return false
+}
+
+// Labels are set on the pods of the executer and select them
+func Labels(executer *appsv1alpha1.Executer) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "Executer",
+		"app.kubernetes.io/instance":   executer.Name,
+		"app.kubernetes.io/part-of":    "sanjagh",
+		"app.kubernetes.io/created-by": "controller-manager",
+	}
+}
+
+func deploymentTemplate(executer *appsv1alpha1.Executer, cfg *Config) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      executer.Name,
+			Namespace: executer.Namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &executer.Spec.Replication,
+			Strategy: deploymentStrategy(executer),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: TrackLabels(executer, TrackStable),
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      TrackLabels(executer, TrackStable),
+					Annotations: templateAnnotations(executer, cfg),
+				},
+				Spec: corev1.PodSpec{
+					Containers:         containers(executer, cfg),
+					InitContainers:     convertContainers(executer.Spec.InitContainers, cfg),
+					Volumes:            append(volumes(executer), filesVolume(executer)...),
+					ServiceAccountName: serviceAccountName(executer),
+					SecurityContext:    podSecurityContext(executer, cfg),
+				},
+			},
+		},
+	}
+
+	setScheduling(executer, &deployment.Spec.Template.Spec)
+	return deployment
+}
+
+// templateAnnotations are the annotations of the pod template known without the live objects
+func templateAnnotations(executer *appsv1alpha1.Executer, cfg *Config) map[string]string {
+	annotations := map[string]string{DefaultsChecksumAnnotation: defaultsChecksum(cfg)}
+	if checksum := filesChecksum(executer); checksum != "" {
+		annotations[FilesChecksumAnnotation] = checksum
+	}
+	return annotations
+}
+
+// containers returns the executer's container followed by its sidecars
+func containers(executer *appsv1alpha1.Executer, cfg *Config) []corev1.Container {
+	main := corev1.Container{
+		Name:            executer.Name,
+		Image:           executer.Spec.Image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         executer.Spec.Commands,
+		VolumeMounts:    append(append([]corev1.VolumeMount{}, executer.Spec.VolumeMounts...), filesMounts(executer)...),
+		SecurityContext: containerSecurityContext(executer.Spec.ContainerSecurityContext, cfg),
+	}
+
+	return append([]corev1.Container{main}, convertContainers(executer.Spec.Sidecars, cfg)...)
+}
+
+func convertContainers(containers []appsv1alpha1.Container, cfg *Config) []corev1.Container {
+	if len(containers) == 0 {
+		return nil
+	}
+
+	result := make([]corev1.Container, 0, len(containers))
+	for _, container := range containers {
+		result = append(result, corev1.Container{
+			Name:            container.Name,
+			Image:           container.Image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         container.Commands,
+			Env:             container.Env,
+			Resources:       container.Resources,
+			VolumeMounts:    container.VolumeMounts,
+			SecurityContext: containerSecurityContext(container.SecurityContext, cfg),
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,130 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// disruptionBudget returns the PodDisruptionBudget of the executer, nil if it has none or has less than 2 replicas
+func disruptionBudget(executer *appsv1alpha1.Executer) *policyv1.PodDisruptionBudget {
+	disruption := executer.Spec.Disruption
+	if disruption == nil || executer.Spec.Replication < 2 {
+		return nil
+	}
+
+	spec := policyv1.PodDisruptionBudgetSpec{
+		Selector:       &metav1.LabelSelector{MatchLabels: Labels(executer)},
+		MinAvailable:   disruption.MinAvailable,
+		MaxUnavailable: disruption.MaxUnavailable,
+	}
+	if spec.MinAvailable == nil && spec.MaxUnavailable == nil {
+		maxUnavailable := intstr.FromInt(1)
+		spec.MaxUnavailable = &maxUnavailable
+	}
+
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      executer.Name,
+			Namespace: executer.Namespace,
+			Labels:    Labels(executer),
+		},
+		Spec: spec,
+	}
+}
+
+// disruptionCondition reports how the pods are protected, the condition is removed if the executer has no disruption
+func disruptionCondition(executer *appsv1alpha1.Executer) metav1.Condition {
+	condition := metav1.Condition{Type: appsv1alpha1.ConditionDisruptionBudget, ObservedGeneration: executer.Generation}
+	switch {
+	case executer.Spec.Disruption == nil:
+	case executer.Spec.Replication < 2:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "InsufficientReplicas"
+		condition.Message = fmt.Sprintf("PodDisruptionBudget is skipped for %d replicas, at least 2 are required", executer.Spec.Replication)
+	default:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Managed"
+		condition.Message = fmt.Sprintf("PodDisruptionBudget %s protects the pods", executer.Name)
+	}
+	return condition
+}
+
+// ReconcileDisruption keeps the PodDisruptionBudget of the executer in sync and reports it in the status,
+// it's removed when the executer has no disruption or not enough replicas
+func (r *executer) ReconcileDisruption(ctx context.Context, executer *appsv1alpha1.Executer, log *zap.Logger) error {
+	desired := disruptionBudget(executer)
+	key := types.NamespacedName{Namespace: executer.Namespace, Name: executer.Name}
+
+	found := &policyv1.PodDisruptionBudget{}
+	if err := r.Get(ctx, key, found); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error("Failed to get PodDisruptionBudget", zap.Error(err))
+			return err
+		}
+		found = nil
+	}
+
+	switch {
+	case desired == nil && found == nil:
+
+	case desired == nil:
+		if !metav1.IsControlledBy(found, executer) {
+			break
+		}
+
+		log.Info("Deleting the PodDisruptionBudget")
+		if err := r.Delete(ctx, found); err != nil && !apierrors.IsNotFound(err) {
+			log.Error("Failed to delete PodDisruptionBudget", zap.Error(err))
+			return err
+		}
+
+	case found == nil:
+		if err := ctrl.SetControllerReference(executer, desired, r.scheme); err != nil {
+			log.Error("Failed to set reference", zap.Error(err))
+			return err
+		}
+
+		log.Info("Creating the PodDisruptionBudget")
+		if err := r.Create(ctx, desired); err != nil {
+			log.Error("Failed to create PodDisruptionBudget", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonDisruptionBudgetFailed, "Failed to create PodDisruptionBudget %s: %v", desired.Name, err)
+			return err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonDisruptionBudgetUpdated, "Created PodDisruptionBudget %s", desired.Name)
+
+	case !metav1.IsControlledBy(found, executer):
+		err := fmt.Errorf("PodDisruptionBudget %s already exists and isn't owned by the executer", found.Name)
+		log.Error("Failed to update PodDisruptionBudget", zap.Error(err))
+		r.recorder.Event(executer, corev1.EventTypeWarning, ReasonDisruptionBudgetFailed, err.Error())
+		return err
+
+	case Sync(found, desired):
+		log.Info("Updating the PodDisruptionBudget")
+		if err := r.Update(ctx, found); err != nil {
+			log.Error("Failed to update PodDisruptionBudget", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonDisruptionBudgetFailed, "Failed to update PodDisruptionBudget %s: %v", found.Name, err)
+			return err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonDisruptionBudgetUpdated, "Updated PodDisruptionBudget %s", found.Name)
+	}
+
+	if err := r.updateCondition(ctx, executer, disruptionCondition(executer)); err != nil {
+		log.Error("Failed to update disruption condition", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
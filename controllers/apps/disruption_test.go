@@ -0,0 +1,132 @@
+package apps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+func TestReconcileDisruption(t *testing.T) {
+	tests := []struct {
+		name        string
+		disruption  *appsv1alpha1.Disruption
+		replication int32
+		// existing is the PodDisruptionBudget found before the reconciliation, owned by the executer or not
+		existing, owned bool
+		err             string
+		budget          bool
+		condition       *metav1.Condition
+		events          []string
+	}{
+		{
+			name:        "created",
+			disruption:  &appsv1alpha1.Disruption{},
+			replication: 2,
+			budget:      true,
+			condition:   &metav1.Condition{Status: metav1.ConditionTrue, Reason: "Managed"},
+			events:      []string{"Normal DisruptionBudgetUpdated"},
+		},
+		{
+			name:        "insufficient replicas",
+			disruption:  &appsv1alpha1.Disruption{},
+			replication: 1,
+			condition:   &metav1.Condition{Status: metav1.ConditionFalse, Reason: "InsufficientReplicas"},
+		},
+		{
+			name:        "insufficient replicas of an owned budget",
+			disruption:  &appsv1alpha1.Disruption{},
+			replication: 1,
+			existing:    true,
+			owned:       true,
+			condition:   &metav1.Condition{Status: metav1.ConditionFalse, Reason: "InsufficientReplicas"},
+		},
+		{
+			name:        "dropped",
+			replication: 2,
+			existing:    true,
+			owned:       true,
+		},
+		{
+			name:        "dropped without owning the budget",
+			replication: 2,
+			existing:    true,
+			budget:      true,
+		},
+		{
+			name:        "not owned",
+			disruption:  &appsv1alpha1.Disruption{},
+			replication: 2,
+			existing:    true,
+			err:         "PodDisruptionBudget worker already exists and isn't owned by the executer",
+			budget:      true,
+			events:      []string{"Warning DisruptionBudgetFailed"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, executer := context.Background(), newTestExecuter()
+			executer.Spec.Disruption, executer.Spec.Replication = test.disruption, test.replication
+
+			r, recorder := newTestReconciler(t, executer)
+			if test.existing {
+				// the budget of another owner protects a different number of pods
+				minAvailable := intstr.FromInt(3)
+				existing := &policyv1.PodDisruptionBudget{
+					ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"},
+					Spec:       policyv1.PodDisruptionBudgetSpec{MinAvailable: &minAvailable},
+				}
+				if test.owned {
+					require.NoError(t, ctrl.SetControllerReference(executer, existing, r.scheme))
+				}
+				require.NoError(t, r.Create(ctx, existing))
+			}
+
+			err := r.ReconcileDisruption(ctx, executer, zap.NewNop())
+			if test.err == "" {
+				require.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+			assert.Equal(t, test.events, recordedEvents(recorder))
+
+			budget := &policyv1.PodDisruptionBudget{}
+			err = r.Get(ctx, client.ObjectKey{Namespace: "default", Name: "worker"}, budget)
+			if !test.budget {
+				assert.True(t, apierrors.IsNotFound(err))
+			} else {
+				require.NoError(t, err)
+				if test.owned || !test.existing {
+					assert.Equal(t, intstr.FromInt(1), *budget.Spec.MaxUnavailable)
+					assert.Equal(t, Labels(executer), budget.Spec.Selector.MatchLabels)
+				} else {
+					assert.Equal(t, intstr.FromInt(3), *budget.Spec.MinAvailable)
+					assert.Empty(t, budget.OwnerReferences)
+				}
+			}
+
+			stored := &appsv1alpha1.Executer{}
+			require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(executer), stored))
+			condition := meta.FindStatusCondition(stored.Status.Conditions, appsv1alpha1.ConditionDisruptionBudget)
+			if test.condition == nil {
+				assert.Nil(t, condition)
+			} else {
+				require.NotNil(t, condition)
+				assert.Equal(t, test.condition.Status, condition.Status)
+				assert.Equal(t, test.condition.Reason, condition.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package apps
+
+// Reasons of the events emitted for Executer objects, they are kept stable
+// so the events of the same kind get aggregated by the event recorder.
+const (
+	ReasonDeploymentCreated       = "DeploymentCreated"
+	ReasonDeploymentScaled        = "DeploymentScaled"
+	ReasonDeploymentUpdated       = "DeploymentUpdated"
+	ReasonDeploymentFailed        = "DeploymentFailed"
+	ReasonDeploymentMigrated      = "DeploymentMigrated"
+	ReasonDriftCorrected          = "DriftCorrected"
+	ReasonDefaultsUpdated         = "DefaultsUpdated"
+	ReasonClaimCreated            = "ClaimCreated"
+	ReasonClaimFailed             = "ClaimFailed"
+	ReasonFilesUpdated            = "FilesUpdated"
+	ReasonFilesFailed             = "FilesFailed"
+	ReasonRBACUpdated             = "RBACUpdated"
+	ReasonRBACFailed              = "RBACFailed"
+	ReasonDisruptionBudgetUpdated = "DisruptionBudgetUpdated"
+	ReasonDisruptionBudgetFailed  = "DisruptionBudgetFailed"
+	ReasonCanaryStarted           = "CanaryStarted"
+	ReasonCanaryPromoted          = "CanaryPromoted"
+	ReasonCanaryFailed            = "CanaryFailed"
+	ReasonRolledBack              = "RolledBack"
+	ReasonRevisionCreated         = "RevisionCreated"
+	ReasonRevisionRestored        = "RevisionRestored"
+	ReasonRevisionFailed          = "RevisionFailed"
+	ReasonFinalizerAdded          = "FinalizerAdded"
+	ReasonFinalizerRemoved        = "FinalizerRemoved"
+)
@@ -2,41 +2,96 @@ package apps
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	genericregistry "k8s.io/apiserver/pkg/registry/generic/registry"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/controllers/metrics"
+	"github.com/mohammadne/sanjagh/pkg/logger"
 )
 
+var tracer = otel.Tracer("github.com/mohammadne/sanjagh/controllers/apps")
+
 // executer reconciles a Executer object
 type executer struct {
 	client.Client
-	scheme *runtime.Scheme
-	logger *zap.Logger
+	// apiReader reads the revisions directly, as their numbers can't be taken from a stale cache
+	apiReader client.Reader
+	configs   *configReader
+	scheme    *runtime.Scheme
+	recorder  record.EventRecorder
+	config    atomic.Pointer[Config]
+	logger    *zap.Logger
+}
+
+func NewExecuter(client client.Client, apiReader client.Reader, scheme *runtime.Scheme, recorder record.EventRecorder, cfg *Config, lg *zap.Logger) *executer {
+	r := &executer{Client: client, apiReader: apiReader, scheme: scheme, recorder: recorder, logger: logger.Named(lg, "executer-controller")}
+	r.configs = newConfigReader(client, apiReader)
+	r.config.Store(cfg)
+	return r
 }
 
-func NewExecuter(client client.Client, scheme *runtime.Scheme, lg *zap.Logger) *executer {
-	return &executer{Client: client, scheme: scheme, logger: lg.Named("executer-controller")}
+// Reload replaces the config of the controller, the executers get the changes on their next reconciliation
+func (r *executer) Reload(cfg *Config) {
+	r.config.Store(cfg)
 }
 
 const executerFinalizer = "apps.mohammadne.me/finalizer"
 
-func (r *executer) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.logger.Named("reconcile")
+func (r *executer) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	log := r.logger.Named("reconcile").With(
+		zap.String("namespace", req.Namespace),
+		zap.String("name", req.Name),
+		zap.String("reconcileID", string(controller.ReconcileIDFromContext(ctx))),
+	)
+
+	ctx, span := tracer.Start(ctx, "executer.Reconcile", trace.WithAttributes(
+		attribute.String("executer.namespace", req.Namespace),
+		attribute.String("executer.name", req.Name),
+	))
+	defer span.End()
+
+	found := true
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "reconciliation failed")
+		}
+
+		if found {
+			metrics.ObserveReconcile(req, result, err)
+		} else {
+			metrics.Forget(req)
+		}
+	}()
 
 	// Fetch the Executer instance
 	// The purpose is check if the Custom Resource for the Kind Executer
@@ -47,6 +102,7 @@ func (r *executer) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 			// If the custom resource is not found then, it usually means that it was deleted or not created
 			// In this way, we will stop the reconciliation
 			log.Info("Executer resource not found. Ignoring since object must be deleted")
+			found = false
 			return ctrl.Result{}, nil
 		}
 
@@ -55,6 +111,8 @@ func (r *executer) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 		return ctrl.Result{}, err
 	}
 
+	log = log.With(zap.Int64("generation", executer.Generation))
+
 	if executer.GetDeletionTimestamp() != nil {
 		if !controllerutil.ContainsFinalizer(executer, executerFinalizer) {
 			return ctrl.Result{}, nil
@@ -74,10 +132,33 @@ func (r *executer) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 			return ctrl.Result{}, err
 		}
 
+		r.recorder.Event(executer, corev1.EventTypeNormal, ReasonFinalizerRemoved, "Removed finalizer "+executerFinalizer)
+
 		return ctrl.Result{Requeue: true}, nil
 	}
 
-	result, err := r.ReconcileDeployment(ctx, req, executer)
+	// the restored spec is reconciled on the next event of the executer
+	if restored, err := r.ReconcileRevisions(ctx, executer, log); err != nil || restored {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.ReconcileRBAC(ctx, executer, log); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.ReconcileClaims(ctx, executer, log); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.ReconcileFiles(ctx, executer, log); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.ReconcileDisruption(ctx, executer, log); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	result, err = r.ReconcileDeployment(ctx, req, executer, log)
 	if err != nil || !result.IsZero() {
 		return result, err
 	}
@@ -97,80 +178,169 @@ func (r *executer) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result
 			return ctrl.Result{}, err
 		}
 
+		r.recorder.Event(executer, corev1.EventTypeNormal, ReasonFinalizerAdded, "Added finalizer "+executerFinalizer)
+
 		return ctrl.Result{Requeue: true}, nil
 	}
 
 	return ctrl.Result{}, nil
 }
 
-func (r *executer) ReconcileDeployment(ctx context.Context, req ctrl.Request, executer *appsv1alpha1.Executer) (ctrl.Result, error) {
-	log := log.FromContext(ctx)
-
+func (r *executer) ReconcileDeployment(ctx context.Context, req ctrl.Request, executer *appsv1alpha1.Executer, log *zap.Logger) (ctrl.Result, error) {
 	// create desired deployment and add the ownerReference to it
-	desiredDeployment := deploymentTemplate(executer)
+	desiredDeployment := deploymentTemplate(executer, r.config.Load())
 	if err := ctrl.SetControllerReference(executer, desiredDeployment, r.scheme); err != nil {
-		log.Error(err, "Failed to set reference", "NamespacedName", req.NamespacedName.String())
+		log.Error("Failed to set reference", zap.Error(err))
 		return ctrl.Result{}, err
 	}
 
+	checksum, err := ConfigChecksum(ctx, r.configs, executer)
+	if err != nil {
+		log.Error("Failed to compute checksum of referenced configs", zap.Error(err))
+		return ctrl.Result{}, err
+	}
+	SetConfigChecksum(desiredDeployment, checksum)
+
+	// the failed rollout of the desired template is kept reverted until the executer or its referenced configs are changed
+	desiredHash := templateHash(&desiredDeployment.Spec.Template)
+	if rolledBack(executer, desiredHash) {
+		copyTemplate(&desiredDeployment.Spec.Template, executer.Status.LastGoodTemplate.DeepCopy())
+	}
+
 	// Check if the deployment already exists, if not create a new one
 	foundDeployment := &appsv1.Deployment{}
 	if err := r.Get(ctx, req.NamespacedName, foundDeployment); err != nil && apierrors.IsNotFound(err) {
-		executer.Status.Phase = appsv1alpha1.PhaseCreating
-		if err := r.Status().Update(ctx, executer); err != nil {
-			log.Error(err, "Failed to update deployment state", "NamespacedName", req.NamespacedName.String())
+		if err := r.updatePhase(ctx, executer, appsv1alpha1.PhaseCreating); err != nil {
+			log.Error("Failed to update deployment state", zap.Error(err))
 			return ctrl.Result{}, err
 		}
 
-		log.Info("Creating a new Deployment", "NamespacedName", req.NamespacedName.String())
+		log.Info("Creating a new Deployment")
 		if err = r.Create(ctx, desiredDeployment); err != nil {
-			executer.Status.Phase = appsv1alpha1.PhaseFailed
-			if err := r.Status().Update(ctx, executer); err != nil {
-				log.Error(err, "Failed to update deployment state", "NamespacedName", req.NamespacedName.String())
+			if err := r.updatePhase(ctx, executer, appsv1alpha1.PhaseFailed); err != nil {
+				log.Error("Failed to update deployment state", zap.Error(err))
 				return ctrl.Result{}, err
 			}
 
-			log.Error(err, "Failed to create new Deployment", "NamespacedName", req.NamespacedName.String())
+			log.Error("Failed to create new Deployment", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonDeploymentFailed, "Failed to create deployment %s: %v", desiredDeployment.Name, err)
 			return ctrl.Result{}, err
 		}
 
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonDeploymentCreated, "Created deployment %s", desiredDeployment.Name)
+
 		// We will requeue the reconciliation so that we can ensure the state and move forward for the next operations
 		return ctrl.Result{RequeueAfter: time.Minute}, nil
 	} else if err != nil {
-		log.Error(err, "Failed to get Deployment")
+		log.Error("Failed to get Deployment", zap.Error(err))
 		return ctrl.Result{}, err
 	}
 
+	// the selector is immutable, so the deployments created before the tracks are recreated with it
+	if foundDeployment.Spec.Selector.MatchLabels[TrackLabel] != TrackStable {
+		return r.migrateDeployment(ctx, executer, foundDeployment, log)
+	}
+
+	metrics.ObserveReplicas(executer, foundDeployment.Status.ReadyReplicas)
+
 	// Update existing deployment spec
-	if *foundDeployment.Spec.Replicas != *desiredDeployment.Spec.Replicas {
-		executer.Status.Phase = appsv1alpha1.PhaseUpdating
-		if err := r.Status().Update(ctx, executer); err != nil {
-			log.Error(err, "Failed to update deployment state", "NamespacedName", req.NamespacedName.String())
+	foundReplicas := *foundDeployment.Spec.Replicas
+	scaled := foundReplicas != *desiredDeployment.Spec.Replicas
+	templateUpdated := templateChanged(foundDeployment, desiredDeployment)
+	updated := templateUpdated || strategyChanged(foundDeployment, desiredDeployment)
+
+	// the template follows the spec of the executer, its referenced configs and the security defaults,
+	// any other change is made out of band
+	foundAnnotations, desiredAnnotations := foundDeployment.Spec.Template.Annotations, desiredDeployment.Spec.Template.Annotations
+	specChanged := executer.Status.ObservedGeneration != executer.Generation
+	configChanged := foundAnnotations[ConfigChecksumAnnotation] != desiredAnnotations[ConfigChecksumAnnotation]
+	defaultsChanged := foundAnnotations[DefaultsChecksumAnnotation] != desiredAnnotations[DefaultsChecksumAnnotation]
+	drifted := !specChanged && !configChanged && !defaultsChanged
+
+	// the changes of the template are gated by the canary, the out of band ones are restored directly
+	promoted, canaryResult, err := r.ReconcileCanary(ctx, executer, desiredDeployment, templateUpdated && !drifted, log)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	updated = updated && promoted
+
+	if !canaryResult.IsZero() && executer.Status.Phase != appsv1alpha1.PhaseUpdating {
+		if err := r.updatePhase(ctx, executer, appsv1alpha1.PhaseUpdating); err != nil {
+			log.Error("Failed to update deployment state", zap.Error(err))
+			return ctrl.Result{}, err
+		}
+	}
+
+	if scaled || updated {
+		if err := r.updatePhase(ctx, executer, appsv1alpha1.PhaseUpdating); err != nil {
+			log.Error("Failed to update deployment state", zap.Error(err))
 			return ctrl.Result{}, err
 		}
 
-		log.Info("Updating executer's deployment replicas", "found", foundDeployment.Spec.Replicas, "desired", desiredDeployment.Spec.Replicas)
+		log.Info("Updating executer's deployment",
+			zap.Int32("found", foundReplicas), zap.Int32("desired", *desiredDeployment.Spec.Replicas), zap.Bool("template", updated))
 		foundDeployment.Spec.Replicas = desiredDeployment.Spec.Replicas
+		if updated {
+			foundDeployment.Spec.Strategy = desiredDeployment.Spec.Strategy
+			copyTemplate(&foundDeployment.Spec.Template, &desiredDeployment.Spec.Template)
+		}
 		if err := r.Update(ctx, foundDeployment); err != nil {
 			if strings.Contains(err.Error(), genericregistry.OptimisticLockErrorMsg) {
 				return reconcile.Result{RequeueAfter: time.Millisecond * 500}, nil
 			}
 
-			executer.Status.Phase = appsv1alpha1.PhaseFailed
-			if err := r.Status().Update(ctx, executer); err != nil {
-				log.Error(err, "Failed to update deployment state", "NamespacedName", req.NamespacedName.String())
+			if err := r.updatePhase(ctx, executer, appsv1alpha1.PhaseFailed); err != nil {
+				log.Error("Failed to update deployment state", zap.Error(err))
 				return ctrl.Result{}, err
 			}
 
-			log.Error(err, "Failed to update Deployment")
+			log.Error("Failed to update Deployment", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonDeploymentFailed, "Failed to update deployment %s: %v", foundDeployment.Name, err)
 			return ctrl.Result{}, err
 		}
+
+		if drifted {
+			metrics.ObserveDriftCorrection(executer)
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonDriftCorrected, "Deployment %s was changed out of band and is restored", foundDeployment.Name)
+		} else {
+			if scaled {
+				r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonDeploymentScaled, "Scaled deployment %s from %d to %d replicas", foundDeployment.Name, foundReplicas, *desiredDeployment.Spec.Replicas)
+			}
+			switch {
+			case updated && specChanged:
+				r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonDeploymentUpdated, "Updated pod template of deployment %s", foundDeployment.Name)
+			case updated && configChanged:
+				r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonDeploymentUpdated, "Updated pod template of deployment %s for the changed configs", foundDeployment.Name)
+			case updated:
+				r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonDefaultsUpdated, "Updated pod template of deployment %s for the reloaded security defaults", foundDeployment.Name)
+			}
+		}
 	}
 
-	if executer.Status.Phase != appsv1alpha1.PhaseCreated {
-		executer.Status.Phase = appsv1alpha1.PhaseCreated
-		if err := r.Status().Update(ctx, executer); err != nil {
-			log.Error(err, "Failed to update deployment state", "NamespacedName", req.NamespacedName.String())
+	// the status of the deployment is stale right after its update
+	if !updated {
+		if err := r.deleteOrphans(ctx, executer, foundDeployment, log); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.ReconcileRollback(ctx, executer, foundDeployment, desiredHash, log); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.updateCurrentRevision(ctx, executer, foundDeployment, desiredHash, log); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// the generation isn't observed until the canary is promoted
+	if !promoted {
+		return canaryResult, nil
+	}
+
+	if executer.Status.Phase != appsv1alpha1.PhaseCreated || executer.Status.ObservedGeneration != executer.Generation {
+		executer.Status.ObservedGeneration = executer.Generation
+		if err := r.updatePhase(ctx, executer, appsv1alpha1.PhaseCreated); err != nil {
+			log.Error("Failed to update deployment state", zap.Error(err))
 			return ctrl.Result{}, err
 		}
 	}
@@ -178,49 +348,162 @@ func (r *executer) ReconcileDeployment(ctx context.Context, req ctrl.Request, ex
 	return ctrl.Result{}, nil
 }
 
-func labels(executer *appsv1alpha1.Executer) map[string]string {
-	return map[string]string{
-		"app.kubernetes.io/name":       "Executer",
-		"app.kubernetes.io/instance":   executer.Name,
-		"app.kubernetes.io/part-of":    "sanjagh",
-		"app.kubernetes.io/created-by": "controller-manager",
+// updatePhase persists the given phase into the status of the executer
+func (r *executer) updatePhase(ctx context.Context, executer *appsv1alpha1.Executer, phase appsv1alpha1.Phase) error {
+	previous := executer.Status.Phase
+	executer.Status.Phase = phase
+	if err := r.Status().Update(ctx, executer); err != nil {
+		return err
+	}
+
+	metrics.ObservePhase(executer, previous)
+	return nil
+}
+
+// updateCondition persists the condition into the status of the executer if it's changed,
+// the condition is removed if its status is empty
+func (r *executer) updateCondition(ctx context.Context, executer *appsv1alpha1.Executer, condition metav1.Condition) error {
+	existing := meta.FindStatusCondition(executer.Status.Conditions, condition.Type)
+	switch {
+	case condition.Status == "" && existing == nil:
+		return nil
+	case condition.Status == "":
+		meta.RemoveStatusCondition(&executer.Status.Conditions, condition.Type)
+	case existing != nil && existing.Status == condition.Status && existing.Reason == condition.Reason &&
+		existing.Message == condition.Message && existing.ObservedGeneration == condition.ObservedGeneration:
+		return nil
+	default:
+		meta.SetStatusCondition(&executer.Status.Conditions, condition)
+	}
+
+	return r.Status().Update(ctx, executer)
+}
+
+// templateChanged reports whether the pod template of the found deployment differs from the desired one
+func templateChanged(found, desired *appsv1.Deployment) bool {
+	foundVolumes, desiredVolumes := found.Spec.Template.Spec.Volumes, desired.Spec.Template.Spec.Volumes
+	return containersChanged(found.Spec.Template.Spec.Containers, desired.Spec.Template.Spec.Containers) ||
+		containersChanged(found.Spec.Template.Spec.InitContainers, desired.Spec.Template.Spec.InitContainers) ||
+		len(foundVolumes) != len(desiredVolumes) || !equality.Semantic.DeepDerivative(desiredVolumes, foundVolumes) ||
+		annotationsChanged(found.Spec.Template.Annotations, desired.Spec.Template.Annotations) ||
+		schedulingChanged(&found.Spec.Template.Spec, &desired.Spec.Template.Spec) ||
+		securityChanged(&found.Spec.Template.Spec, &desired.Spec.Template.Spec)
+}
+
+// templateHash identifies the desired pod template, so its failed rollouts aren't retried
+func templateHash(template *corev1.PodTemplateSpec) string {
+	data, _ := json.Marshal(template)
+	return revisionHash(data)
+}
+
+// copyTemplate copies the fields of the pod template set by the controller, the rest are kept untouched
+func copyTemplate(found, desired *corev1.PodTemplateSpec) {
+	found.Spec.Containers = desired.Spec.Containers
+	found.Spec.InitContainers = desired.Spec.InitContainers
+	found.Spec.Volumes = desired.Spec.Volumes
+	copyScheduling(&found.Spec, &desired.Spec)
+	copySecurity(&found.Spec, &desired.Spec)
+	for _, annotation := range managedAnnotations {
+		setAnnotation(&found.ObjectMeta, annotation, desired.Annotations[annotation])
+	}
+}
+
+// managedAnnotations are the annotations of the pod template owned by the controller, the others are kept untouched
+var managedAnnotations = []string{ConfigChecksumAnnotation, FilesChecksumAnnotation, DefaultsChecksumAnnotation}
+
+func annotationsChanged(found, desired map[string]string) bool {
+	for _, annotation := range managedAnnotations {
+		if found[annotation] != desired[annotation] {
+			return true
+		}
+	}
+	return false
+}
+
+// setAnnotation sets the annotation on the object, or removes it if the value is empty
+func setAnnotation(object *metav1.ObjectMeta, key, value string) {
+	if value == "" {
+		delete(object.Annotations, key)
+		return
+	}
+
+	if object.Annotations == nil {
+		object.Annotations = map[string]string{}
+	}
+	object.Annotations[key] = value
+}
+
+// containersChanged compares the fields of the containers set by the controller strictly,
+// so the removed env variables, mounts and resources are noticed as well as the changed ones
+func containersChanged(found, desired []corev1.Container) bool {
+	if len(found) != len(desired) {
+		return true
+	}
+
+	for index := range desired {
+		if !equality.Semantic.DeepEqual(ownedContainer(&found[index]), ownedContainer(&desired[index])) {
+			return true
+		}
 	}
+
+	return false
 }
 
-func deploymentTemplate(executer *appsv1alpha1.Executer) *appsv1.Deployment {
-	return &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      executer.Name,
-			Namespace: executer.Namespace,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &executer.Spec.Replication,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: labels(executer),
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels(executer),
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:            executer.Name,
-							Image:           executer.Spec.Image,
-							ImagePullPolicy: corev1.PullIfNotPresent,
-							Command:         executer.Spec.Commands,
-						},
-					},
-				},
-			},
-		},
+// ownedContainer keeps the fields of the container set by the controller,
+// filled with the defaults of the api-server so the found and desired containers are comparable
+func ownedContainer(container *corev1.Container) corev1.Container {
+	owned := corev1.Container{
+		Name:            container.Name,
+		Image:           container.Image,
+		ImagePullPolicy: container.ImagePullPolicy,
+		Command:         container.Command,
+		Env:             make([]corev1.EnvVar, 0, len(container.Env)),
+		Resources:       *container.Resources.DeepCopy(),
+		VolumeMounts:    container.VolumeMounts,
+		SecurityContext: container.SecurityContext,
+	}
+
+	for _, env := range container.Env {
+		if env.ValueFrom != nil && env.ValueFrom.FieldRef != nil && env.ValueFrom.FieldRef.APIVersion == "" {
+			env = *env.DeepCopy()
+			env.ValueFrom.FieldRef.APIVersion = "v1"
+		}
+		owned.Env = append(owned.Env, env)
+	}
+
+	// the requests default to the limits
+	for name, limit := range owned.Resources.Limits {
+		if _, ok := owned.Resources.Requests[name]; !ok {
+			if owned.Resources.Requests == nil {
+				owned.Resources.Requests = corev1.ResourceList{}
+			}
+			owned.Resources.Requests[name] = limit
+		}
 	}
+
+	return owned
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *executer) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &appsv1alpha1.Executer{}, configReferenceIndex, func(object client.Object) []string {
+		return configReferences(object.(*appsv1alpha1.Executer))
+	})
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&appsv1alpha1.Executer{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.ControllerRevision{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.referencingExecuters("ConfigMap"))).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.referencingExecuters("Secret")), builder.OnlyMetadata).
 		Complete(r)
 }
@@ -0,0 +1,389 @@
+package apps
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// newTestExecuter returns an executer whose current generation is reconciled, referencing the settings ConfigMap
+func newTestExecuter() *appsv1alpha1.Executer {
+	return &appsv1alpha1.Executer{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default", UID: "worker-uid", Generation: 1},
+		Spec: appsv1alpha1.ExecuterSpec{
+			Image:        "worker:v1",
+			Replication:  2,
+			VolumeMounts: []corev1.VolumeMount{{Name: "settings", MountPath: "/etc/worker"}},
+			Volumes: []appsv1alpha1.Volume{{
+				Name:      "settings",
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}},
+			}},
+		},
+		Status: appsv1alpha1.ExecuterStatus{Phase: appsv1alpha1.PhaseCreated, ObservedGeneration: 1},
+	}
+}
+
+// newTestReconciler returns the reconciler backed by a fake client holding the objects
+func newTestReconciler(t *testing.T, objects ...client.Object) (*executer, *record.FakeRecorder) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, appsv1alpha1.AddToScheme(scheme))
+
+	recorder := record.NewFakeRecorder(100)
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	return NewExecuter(client, client, scheme, recorder, &Config{}, zap.NewNop()), recorder
+}
+
+// desiredDeployment returns the deployment of the executer as the controller creates it
+func desiredDeployment(t *testing.T, r *executer, executer *appsv1alpha1.Executer) *appsv1.Deployment {
+	deployment := deploymentTemplate(executer, r.config.Load())
+	require.NoError(t, ctrl.SetControllerReference(executer, deployment, r.scheme))
+
+	checksum, err := ConfigChecksum(context.Background(), r.Client, executer)
+	require.NoError(t, err)
+	SetConfigChecksum(deployment, checksum)
+	return deployment
+}
+
+// reconcileDeployment runs ReconcileDeployment on the stored executer and returns it with the reconciled deployment
+func reconcileDeployment(t *testing.T, r *executer, name string) (*appsv1alpha1.Executer, *appsv1.Deployment, ctrl.Result) {
+	ctx, key := context.Background(), client.ObjectKey{Namespace: "default", Name: name}
+
+	executer := &appsv1alpha1.Executer{}
+	require.NoError(t, r.Get(ctx, key, executer))
+
+	result, err := r.ReconcileDeployment(ctx, ctrl.Request{NamespacedName: key}, executer, zap.NewNop())
+	require.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, key, deployment))
+	return executer, deployment, result
+}
+
+// recordedEvents drains the events recorded so far as "type reason" pairs
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			fields := strings.Fields(event)
+			events = append(events, fields[0]+" "+fields[1])
+		default:
+			return events
+		}
+	}
+}
+
+func TestContainersChanged(t *testing.T) {
+	desired := func() []corev1.Container {
+		return []corev1.Container{{
+			Name:            "worker",
+			Image:           "worker:v1",
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Env: []corev1.EnvVar{
+				{Name: "MODE", Value: "batch"},
+				{Name: "POD", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+			},
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+			},
+			VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}},
+		}}
+	}
+
+	// found is the desired container as defaulted by the api-server
+	found := func() []corev1.Container {
+		containers := desired()
+		containers[0].TerminationMessagePath = corev1.TerminationMessagePathDefault
+		containers[0].TerminationMessagePolicy = corev1.TerminationMessageReadFile
+		containers[0].Env[1].ValueFrom.FieldRef.APIVersion = "v1"
+		containers[0].Resources.Requests = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")}
+		return containers
+	}
+
+	tests := []struct {
+		name    string
+		change  func(containers []corev1.Container) []corev1.Container
+		changed bool
+	}{
+		{
+			name:    "defaulted by the api-server",
+			change:  func(containers []corev1.Container) []corev1.Container { return containers },
+			changed: false,
+		},
+		{
+			name: "env variable removed",
+			change: func(containers []corev1.Container) []corev1.Container {
+				containers[0].Env = containers[0].Env[1:]
+				return containers
+			},
+			changed: true,
+		},
+		{
+			name: "volume mount removed",
+			change: func(containers []corev1.Container) []corev1.Container {
+				containers[0].VolumeMounts = nil
+				return containers
+			},
+			changed: true,
+		},
+		{
+			name: "limits removed",
+			change: func(containers []corev1.Container) []corev1.Container {
+				containers[0].Resources = corev1.ResourceRequirements{}
+				return containers
+			},
+			changed: true,
+		},
+		{
+			name: "image changed",
+			change: func(containers []corev1.Container) []corev1.Container {
+				containers[0].Image = "worker:v2"
+				return containers
+			},
+			changed: true,
+		},
+		{
+			name: "container removed",
+			change: func(containers []corev1.Container) []corev1.Container {
+				return nil
+			},
+			changed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.changed, containersChanged(found(), test.change(desired())))
+		})
+	}
+}
+
+func TestReconcileDeploymentChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(executer *appsv1alpha1.Executer, deployment *appsv1.Deployment, settings *corev1.ConfigMap)
+		reload   func(cfg *Config)
+		image    string
+		events   []string
+		canaried bool
+	}{
+		{
+			name:   "unchanged",
+			change: func(*appsv1alpha1.Executer, *appsv1.Deployment, *corev1.ConfigMap) {},
+			image:  "worker:v1",
+		},
+		{
+			name: "spec changed",
+			change: func(executer *appsv1alpha1.Executer, _ *appsv1.Deployment, _ *corev1.ConfigMap) {
+				executer.Spec.Image, executer.Generation = "worker:v2", 2
+			},
+			image:  "worker:v2",
+			events: []string{"Normal DeploymentUpdated"},
+		},
+		{
+			name: "referenced config changed",
+			change: func(_ *appsv1alpha1.Executer, _ *appsv1.Deployment, settings *corev1.ConfigMap) {
+				settings.Data["level"] = "debug"
+			},
+			image:  "worker:v1",
+			events: []string{"Normal DeploymentUpdated"},
+		},
+		{
+			name: "deployment changed out of band",
+			change: func(_ *appsv1alpha1.Executer, deployment *appsv1.Deployment, _ *corev1.ConfigMap) {
+				deployment.Spec.Template.Spec.Containers[0].Image = "worker:debug"
+			},
+			image:  "worker:v1",
+			events: []string{"Warning DriftCorrected"},
+		},
+		{
+			name: "referenced config changed with canary",
+			change: func(executer *appsv1alpha1.Executer, _ *appsv1.Deployment, settings *corev1.ConfigMap) {
+				executer.Spec.Strategy = &appsv1alpha1.Strategy{Type: appsv1alpha1.StrategyCanary}
+				settings.Data["level"] = "debug"
+			},
+			image:    "worker:v1",
+			events:   []string{"Normal CanaryStarted"},
+			canaried: true,
+		},
+		{
+			name:   "security defaults reloaded",
+			change: func(*appsv1alpha1.Executer, *appsv1.Deployment, *corev1.ConfigMap) {},
+			reload: func(cfg *Config) {
+				cfg.Security.Defaults.RunAsNonRoot = true
+			},
+			image:  "worker:v1",
+			events: []string{"Normal DefaultsUpdated"},
+		},
+		{
+			name: "security defaults reloaded with canary",
+			change: func(executer *appsv1alpha1.Executer, _ *appsv1.Deployment, _ *corev1.ConfigMap) {
+				executer.Spec.Strategy = &appsv1alpha1.Strategy{Type: appsv1alpha1.StrategyCanary}
+			},
+			reload: func(cfg *Config) {
+				cfg.Security.Defaults.RunAsNonRoot = true
+			},
+			image:    "worker:v1",
+			events:   []string{"Normal CanaryStarted"},
+			canaried: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executer := newTestExecuter()
+			settings := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+				Data:       map[string]string{"level": "info"},
+			}
+
+			r, recorder := newTestReconciler(t, settings)
+			deployment := desiredDeployment(t, r, executer)
+			test.change(executer, deployment, settings)
+			require.NoError(t, r.Create(context.Background(), executer))
+			require.NoError(t, r.Create(context.Background(), deployment))
+			require.NoError(t, r.Update(context.Background(), settings))
+			if test.reload != nil {
+				cfg := &Config{}
+				test.reload(cfg)
+				r.Reload(cfg)
+			}
+
+			_, deployment, _ = reconcileDeployment(t, r, executer.Name)
+			assert.Equal(t, test.image, deployment.Spec.Template.Spec.Containers[0].Image)
+			assert.Equal(t, test.events, recordedEvents(recorder))
+
+			checksum, err := ConfigChecksum(context.Background(), r.Client, executer)
+			require.NoError(t, err)
+			assert.Equal(t, !test.canaried, checksum == deployment.Spec.Template.Annotations[ConfigChecksumAnnotation] &&
+				defaultsChecksum(r.config.Load()) == deployment.Spec.Template.Annotations[DefaultsChecksumAnnotation])
+
+			canary := &appsv1.Deployment{}
+			err = r.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: CanaryName(executer)}, canary)
+			assert.Equal(t, test.canaried, err == nil)
+		})
+	}
+}
+
+// failedDeploymentWrites fails the writes of the deployments as the api-server may reject them
+type failedDeploymentWrites struct {
+	client.Client
+}
+
+func (c failedDeploymentWrites) Create(ctx context.Context, object client.Object, opts ...client.CreateOption) error {
+	if _, ok := object.(*appsv1.Deployment); ok {
+		return errors.New("quota exceeded")
+	}
+	return c.Client.Create(ctx, object, opts...)
+}
+
+func (c failedDeploymentWrites) Update(ctx context.Context, object client.Object, opts ...client.UpdateOption) error {
+	if _, ok := object.(*appsv1.Deployment); ok {
+		return errors.New("quota exceeded")
+	}
+	return c.Client.Update(ctx, object, opts...)
+}
+
+func TestReconcileDeploymentEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		created bool
+		change  func(executer *appsv1alpha1.Executer)
+		failed  bool
+		// phase is the one reported on creation or failure, checked when it's set
+		phase  appsv1alpha1.Phase
+		events []string
+	}{
+		{
+			name:   "created",
+			change: func(*appsv1alpha1.Executer) {},
+			phase:  appsv1alpha1.PhaseCreating,
+			events: []string{"Normal DeploymentCreated Created deployment worker"},
+		},
+		{
+			name:   "create failed",
+			change: func(*appsv1alpha1.Executer) {},
+			failed: true,
+			phase:  appsv1alpha1.PhaseFailed,
+			events: []string{"Warning DeploymentFailed Failed to create deployment worker: quota exceeded"},
+		},
+		{
+			name:    "scaled",
+			created: true,
+			change: func(executer *appsv1alpha1.Executer) {
+				executer.Spec.Replication, executer.Generation = 3, 2
+			},
+			events: []string{"Normal DeploymentScaled Scaled deployment worker from 2 to 3 replicas"},
+		},
+		{
+			name:    "updated",
+			created: true,
+			change: func(executer *appsv1alpha1.Executer) {
+				executer.Spec.Image, executer.Generation = "worker:v2", 2
+			},
+			events: []string{"Normal DeploymentUpdated Updated pod template of deployment worker"},
+		},
+		{
+			name:    "update failed",
+			created: true,
+			change: func(executer *appsv1alpha1.Executer) {
+				executer.Spec.Image, executer.Generation = "worker:v2", 2
+			},
+			failed: true,
+			phase:  appsv1alpha1.PhaseFailed,
+			events: []string{"Warning DeploymentFailed Failed to update deployment worker: quota exceeded"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, executer := context.Background(), newTestExecuter()
+			r, recorder := newTestReconciler(t)
+			if test.created {
+				require.NoError(t, r.Create(ctx, desiredDeployment(t, r, executer)))
+			}
+			test.change(executer)
+			require.NoError(t, r.Create(ctx, executer))
+			if test.failed {
+				r.Client = failedDeploymentWrites{Client: r.Client}
+			}
+
+			_, err := r.ReconcileDeployment(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(executer)}, executer, zap.NewNop())
+			if test.failed {
+				assert.EqualError(t, err, "quota exceeded")
+			} else {
+				require.NoError(t, err)
+			}
+
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			assert.Equal(t, test.events, events)
+
+			if test.phase != "" {
+				stored := &appsv1alpha1.Executer{}
+				require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(executer), stored))
+				assert.Equal(t, test.phase, stored.Status.Phase)
+			}
+		})
+	}
+}
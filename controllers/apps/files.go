@@ -0,0 +1,178 @@
+package apps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// FilesVolume is the name of the volume of the inline files, reserved among the executer's volumes
+const FilesVolume = "sanjagh-files"
+
+// FilesChecksumAnnotation holds the checksum of the inline files on the pod template, so their changes rollout the pods
+const FilesChecksumAnnotation = "apps.mohammadne.me/files-checksum"
+
+// FilesConfigMapName is the name of the ConfigMap keeping the inline files of the executer
+func FilesConfigMapName(executer *appsv1alpha1.Executer) string {
+	return executer.Name + "-files"
+}
+
+// FileKey is the key of the file in the ConfigMap, as the keys can't contain slashes
+func FileKey(path string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", "_")
+}
+
+// filesConfigMap returns the ConfigMap of the inline files, nil if there is none
+func filesConfigMap(executer *appsv1alpha1.Executer) *corev1.ConfigMap {
+	if len(executer.Spec.Files) == 0 {
+		return nil
+	}
+
+	data := make(map[string]string, len(executer.Spec.Files))
+	for path, content := range executer.Spec.Files {
+		data[FileKey(path)] = content
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      FilesConfigMapName(executer),
+			Namespace: executer.Namespace,
+			Labels:    Labels(executer),
+		},
+		Data: data,
+	}
+}
+
+// filesMounts mounts each of the inline files at its path
+func filesMounts(executer *appsv1alpha1.Executer) []corev1.VolumeMount {
+	paths := filesPaths(executer)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	mounts := make([]corev1.VolumeMount, 0, len(paths))
+	for _, path := range paths {
+		mounts = append(mounts, corev1.VolumeMount{Name: FilesVolume, MountPath: path, SubPath: FileKey(path), ReadOnly: true})
+	}
+	return mounts
+}
+
+func filesVolume(executer *appsv1alpha1.Executer) []corev1.Volume {
+	if len(executer.Spec.Files) == 0 {
+		return nil
+	}
+
+	return []corev1.Volume{{
+		Name: FilesVolume,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: FilesConfigMapName(executer)},
+			},
+		},
+	}}
+}
+
+// filesChecksum hashes the inline files, empty if there is none
+func filesChecksum(executer *appsv1alpha1.Executer) string {
+	paths := filesPaths(executer)
+	if len(paths) == 0 {
+		return ""
+	}
+
+	hash := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(hash, "%s\n%x\n", path, executer.Spec.Files[path])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func filesPaths(executer *appsv1alpha1.Executer) []string {
+	paths := make([]string, 0, len(executer.Spec.Files))
+	for path := range executer.Spec.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ReconcileFiles keeps the ConfigMap of the inline files in sync, it's removed when the executer has no files
+func (r *executer) ReconcileFiles(ctx context.Context, executer *appsv1alpha1.Executer, log *zap.Logger) error {
+	desired := filesConfigMap(executer)
+	key := types.NamespacedName{Namespace: executer.Namespace, Name: FilesConfigMapName(executer)}
+
+	found := &corev1.ConfigMap{}
+	if err := r.Get(ctx, key, found); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error("Failed to get files ConfigMap", zap.Error(err))
+			return err
+		}
+		found = nil
+	}
+
+	switch {
+	case desired == nil && found == nil:
+		return nil
+
+	case desired == nil:
+		if !metav1.IsControlledBy(found, executer) {
+			return nil
+		}
+
+		log.Info("Deleting the files ConfigMap")
+		if err := r.Delete(ctx, found); err != nil && !apierrors.IsNotFound(err) {
+			log.Error("Failed to delete files ConfigMap", zap.Error(err))
+			return err
+		}
+		return nil
+	}
+
+	if err := ctrl.SetControllerReference(executer, desired, r.scheme); err != nil {
+		log.Error("Failed to set reference", zap.Error(err))
+		return err
+	}
+
+	if found == nil {
+		log.Info("Creating the files ConfigMap")
+		if err := r.Create(ctx, desired); err != nil {
+			log.Error("Failed to create files ConfigMap", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonFilesFailed, "Failed to create ConfigMap %s: %v", desired.Name, err)
+			return err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonFilesUpdated, "Created ConfigMap %s with %d files", desired.Name, len(desired.Data))
+		return nil
+	}
+
+	if !metav1.IsControlledBy(found, executer) {
+		err := fmt.Errorf("ConfigMap %s already exists and isn't owned by the executer", found.Name)
+		log.Error("Failed to update files ConfigMap", zap.Error(err))
+		r.recorder.Event(executer, corev1.EventTypeWarning, ReasonFilesFailed, err.Error())
+		return err
+	}
+
+	if !Sync(found, desired) {
+		return nil
+	}
+
+	log.Info("Updating the files ConfigMap")
+	if err := r.Update(ctx, found); err != nil {
+		log.Error("Failed to update files ConfigMap", zap.Error(err))
+		r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonFilesFailed, "Failed to update ConfigMap %s: %v", found.Name, err)
+		return err
+	}
+
+	r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonFilesUpdated, "Updated ConfigMap %s with %d files", found.Name, len(found.Data))
+	return nil
+}
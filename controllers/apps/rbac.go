@@ -0,0 +1,159 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/pkg/rbac"
+)
+
+// checkCeiling is the check of the webhook repeated before the role is granted,
+// as the webhook can be bypassed or run with another ceiling
+func checkCeiling(ceiling []rbac.Rule, rules []rbacv1.PolicyRule) error {
+	for index, rule := range rules {
+		if len(rule.NonResourceURLs) > 0 {
+			return fmt.Errorf("rule %d can't grant non-resource URLs", index)
+		}
+
+		if permissions := rbac.EscalatingPermissions(ceiling, rule); len(permissions) > 0 {
+			permission := permissions[0]
+			return fmt.Errorf("rule %d escalates beyond the ceiling: verb '%s' on resource '%s' of api group '%s'",
+				index, permission.Verb, permission.Resource, permission.APIGroup)
+		}
+	}
+	return nil
+}
+
+// serviceAccountName is the identity of the executer's pods, the provisioned one takes precedence
+func serviceAccountName(executer *appsv1alpha1.Executer) string {
+	if executer.Spec.RBAC != nil {
+		return executer.Name
+	}
+	return executer.Spec.ServiceAccountName
+}
+
+// rbacObjects returns the ServiceAccount, Role and RoleBinding of the executer, nil if it has no rbac section
+func rbacObjects(executer *appsv1alpha1.Executer) []client.Object {
+	if executer.Spec.RBAC == nil {
+		return nil
+	}
+
+	meta := func() metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: executer.Name, Namespace: executer.Namespace, Labels: Labels(executer)}
+	}
+
+	return []client.Object{
+		&corev1.ServiceAccount{ObjectMeta: meta()},
+		&rbacv1.Role{ObjectMeta: meta(), Rules: executer.Spec.RBAC.Rules},
+		&rbacv1.RoleBinding{
+			ObjectMeta: meta(),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: executer.Name},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: executer.Name, Namespace: executer.Namespace}},
+		},
+	}
+}
+
+// ReconcileRBAC keeps the ServiceAccount, Role and RoleBinding of the executer in sync,
+// they are removed when the executer has no rbac section
+func (r *executer) ReconcileRBAC(ctx context.Context, executer *appsv1alpha1.Executer, log *zap.Logger) error {
+	desired := rbacObjects(executer)
+	if desired != nil {
+		if err := checkCeiling(r.config.Load().RBAC.Ceiling, executer.Spec.RBAC.Rules); err != nil {
+			log.Error("Refusing to grant the rbac rules", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonRBACFailed, "Refused to grant the rules of role %s: %v", executer.Name, err)
+			return err
+		}
+	}
+
+	kinds := []string{"ServiceAccount", "Role", "RoleBinding"}
+	found := []client.Object{&corev1.ServiceAccount{}, &rbacv1.Role{}, &rbacv1.RoleBinding{}}
+
+	for index, kind := range kinds {
+		var object client.Object
+		if desired != nil {
+			object = desired[index]
+		}
+
+		if err := r.reconcileRBACObject(ctx, executer, kind, object, found[index], log.With(zap.String("kind", kind))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *executer) reconcileRBACObject(ctx context.Context, executer *appsv1alpha1.Executer, kind string, desired, found client.Object, log *zap.Logger) error {
+	key := types.NamespacedName{Namespace: executer.Namespace, Name: executer.Name}
+	if err := r.Get(ctx, key, found); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error("Failed to get rbac object", zap.Error(err))
+			return err
+		}
+		found = nil
+	}
+
+	switch {
+	case desired == nil && found == nil:
+		return nil
+
+	case desired == nil:
+		if !metav1.IsControlledBy(found, executer) {
+			return nil
+		}
+
+		log.Info("Deleting the rbac object")
+		if err := r.Delete(ctx, found); err != nil && !apierrors.IsNotFound(err) {
+			log.Error("Failed to delete rbac object", zap.Error(err))
+			return err
+		}
+		return nil
+	}
+
+	if err := ctrl.SetControllerReference(executer, desired, r.scheme); err != nil {
+		log.Error("Failed to set reference", zap.Error(err))
+		return err
+	}
+
+	if found == nil {
+		log.Info("Creating the rbac object")
+		if err := r.Create(ctx, desired); err != nil {
+			log.Error("Failed to create rbac object", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonRBACFailed, "Failed to create %s %s: %v", kind, desired.GetName(), err)
+			return err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonRBACUpdated, "Created %s %s", kind, desired.GetName())
+		return nil
+	}
+
+	if !metav1.IsControlledBy(found, executer) {
+		err := fmt.Errorf("%s %s already exists and isn't owned by the executer", kind, found.GetName())
+		log.Error("Failed to update rbac object", zap.Error(err))
+		r.recorder.Event(executer, corev1.EventTypeWarning, ReasonRBACFailed, err.Error())
+		return err
+	}
+
+	if !Sync(found, desired) {
+		return nil
+	}
+
+	log.Info("Updating the rbac object")
+	if err := r.Update(ctx, found); err != nil {
+		log.Error("Failed to update rbac object", zap.Error(err))
+		r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonRBACFailed, "Failed to update %s %s: %v", kind, found.GetName(), err)
+		return err
+	}
+
+	r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonRBACUpdated, "Updated %s %s", kind, found.GetName())
+	return nil
+}
@@ -0,0 +1,67 @@
+package apps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/pkg/rbac"
+)
+
+func TestReconcileRBACCeiling(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []rbacv1.PolicyRule
+		granted bool
+		events  []string
+	}{
+		{
+			name:    "covered by the ceiling",
+			rules:   []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}},
+			granted: true,
+			events:  []string{"Normal RBACUpdated", "Normal RBACUpdated", "Normal RBACUpdated"},
+		},
+		{
+			name:   "escalating beyond the ceiling",
+			rules:  []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+			events: []string{"Warning RBACFailed"},
+		},
+		{
+			name:   "non-resource urls",
+			rules:  []rbacv1.PolicyRule{{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}}},
+			events: []string{"Warning RBACFailed"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executer := newTestExecuter()
+			executer.Spec.RBAC = &appsv1alpha1.RBAC{Rules: test.rules}
+
+			r, recorder := newTestReconciler(t, executer)
+			cfg := &Config{}
+			cfg.RBAC.Ceiling = []rbac.Rule{{APIGroups: []string{""}, Resources: []string{"configmaps", "pods"}, Verbs: []string{"get", "list", "watch"}}}
+			r.Reload(cfg)
+
+			err := r.ReconcileRBAC(context.Background(), executer, zap.NewNop())
+			assert.Equal(t, test.granted, err == nil)
+			assert.Equal(t, test.events, recordedEvents(recorder))
+
+			role := &rbacv1.Role{}
+			err = r.Get(context.Background(), client.ObjectKeyFromObject(executer), role)
+			if test.granted {
+				require.NoError(t, err)
+				assert.Equal(t, test.rules, role.Rules)
+			} else {
+				assert.True(t, apierrors.IsNotFound(err))
+			}
+		})
+	}
+}
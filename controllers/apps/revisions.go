@@ -0,0 +1,219 @@
+package apps
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// RevisionHashLabel holds the hash of the spec kept in the ControllerRevision
+const RevisionHashLabel = "apps.mohammadne.me/revision-hash"
+
+// revisionData returns the spec kept in the revisions, without the fields which aren't part of the history
+func revisionData(executer *appsv1alpha1.Executer) ([]byte, error) {
+	spec := executer.Spec.DeepCopy()
+	spec.RollbackTo = nil
+	return json.Marshal(spec)
+}
+
+func revisionHash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])[:10]
+}
+
+// Revisions returns the ControllerRevisions owned by the executer sorted by their revision numbers
+func Revisions(ctx context.Context, reader client.Reader, executer *appsv1alpha1.Executer) ([]appsv1.ControllerRevision, error) {
+	list := &appsv1.ControllerRevisionList{}
+	if err := reader.List(ctx, list, client.InNamespace(executer.Namespace), client.MatchingLabels(Labels(executer))); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]appsv1.ControllerRevision, 0, len(list.Items))
+	for _, revision := range list.Items {
+		if metav1.IsControlledBy(&revision, executer) {
+			revisions = append(revisions, revision)
+		}
+	}
+
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+	return revisions, nil
+}
+
+// RevisionSpec decodes the spec kept in the revision
+func RevisionSpec(revision *appsv1.ControllerRevision) (*appsv1alpha1.ExecuterSpec, error) {
+	spec := &appsv1alpha1.ExecuterSpec{}
+	if err := json.Unmarshal(revision.Data.Raw, spec); err != nil {
+		return nil, fmt.Errorf("error decoding revision %s: %v", revision.Name, err)
+	}
+	return spec, nil
+}
+
+// ReconcileRevisions restores the spec of the revision requested by rollbackTo, and reports it as the executer
+// is updated. Otherwise the spec is kept in the history as the update revision and the old revisions are pruned.
+func (r *executer) ReconcileRevisions(ctx context.Context, executer *appsv1alpha1.Executer, log *zap.Logger) (bool, error) {
+	// the revisions are read from the api server, as the cache may miss the one created by the previous reconciliation
+	revisions, err := Revisions(ctx, r.apiReader, executer)
+	if err != nil {
+		log.Error("Failed to list revisions", zap.Error(err))
+		return false, err
+	}
+
+	if executer.Spec.RollbackTo != nil {
+		return true, r.restoreRevision(ctx, executer, revisions, log)
+	}
+
+	data, err := revisionData(executer)
+	if err != nil {
+		log.Error("Failed to encode revision", zap.Error(err))
+		return false, err
+	}
+
+	hash := revisionHash(data)
+	name := executer.Name + "-" + hash
+
+	var latest int64
+	var found *appsv1.ControllerRevision
+	for index := range revisions {
+		latest = revisions[index].Revision
+		if revisions[index].Name == name {
+			found = &revisions[index]
+		}
+	}
+
+	switch {
+	case found == nil:
+		labels := Labels(executer)
+		labels[RevisionHashLabel] = hash
+		revision := &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: executer.Namespace, Labels: labels},
+			Data:       runtime.RawExtension{Raw: data},
+			Revision:   latest + 1,
+		}
+		if err := ctrl.SetControllerReference(executer, revision, r.scheme); err != nil {
+			log.Error("Failed to set reference", zap.Error(err))
+			return false, err
+		}
+
+		log.Info("Creating a new revision", zap.Int64("revision", revision.Revision))
+		if err := r.Create(ctx, revision); err != nil {
+			log.Error("Failed to create revision", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonRevisionFailed, "Failed to create revision %d: %v", revision.Revision, err)
+			return false, err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonRevisionCreated, "Created revision %d", revision.Revision)
+		revisions = append(revisions, *revision)
+
+	case !bytes.Equal(found.Data.Raw, data):
+		err := fmt.Errorf("revision %s has the same hash of a different spec", found.Name)
+		log.Error("Failed to create revision", zap.Error(err))
+		return false, err
+
+	case found.Revision < latest:
+		// the spec is changed back to an older revision, which becomes the latest one
+		log.Info("Reusing an old revision", zap.Int64("revision", found.Revision), zap.Int64("latest", latest+1))
+		found.Revision = latest + 1
+		if err := r.Update(ctx, found); err != nil {
+			log.Error("Failed to update revision", zap.Error(err))
+			return false, err
+		}
+	}
+
+	if executer.Status.UpdateRevision != name {
+		executer.Status.UpdateRevision = name
+		if err := r.Status().Update(ctx, executer); err != nil {
+			log.Error("Failed to update revision status", zap.Error(err))
+			return false, err
+		}
+	}
+
+	return false, r.pruneRevisions(ctx, executer, revisions, log)
+}
+
+// restoreRevision replaces the spec of the executer with the one of the requested revision
+func (r *executer) restoreRevision(ctx context.Context, executer *appsv1alpha1.Executer, revisions []appsv1.ControllerRevision, log *zap.Logger) error {
+	number := *executer.Spec.RollbackTo
+	executer.Spec.RollbackTo = nil
+
+	var spec *appsv1alpha1.ExecuterSpec
+	for index := range revisions {
+		if revisions[index].Revision != number {
+			continue
+		}
+
+		var err error
+		if spec, err = RevisionSpec(&revisions[index]); err != nil {
+			log.Error("Failed to decode revision", zap.Error(err))
+			return err
+		}
+	}
+
+	if spec != nil {
+		executer.Spec = *spec
+	}
+
+	log.Info("Restoring a revision", zap.Int64("revision", number), zap.Bool("found", spec != nil))
+	if err := r.Update(ctx, executer); err != nil {
+		log.Error("Failed to restore revision", zap.Error(err))
+		r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonRevisionFailed, "Failed to restore revision %d: %v", number, err)
+		return err
+	}
+
+	if spec == nil {
+		r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonRevisionFailed, "Revision %d isn't found in the history", number)
+	} else {
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonRevisionRestored, "Restored the spec of revision %d", number)
+	}
+	return nil
+}
+
+// pruneRevisions deletes the oldest revisions beyond the history limit, the current and update ones are kept
+func (r *executer) pruneRevisions(ctx context.Context, executer *appsv1alpha1.Executer, revisions []appsv1.ControllerRevision, log *zap.Logger) error {
+	old := make([]appsv1.ControllerRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		if revision.Name != executer.Status.CurrentRevision && revision.Name != executer.Status.UpdateRevision {
+			old = append(old, revision)
+		}
+	}
+
+	limit := int(r.config.Load().Revisions.HistoryLimit)
+	for index := 0; index < len(old)-limit; index++ {
+		log.Info("Deleting an old revision", zap.Int64("revision", old[index].Revision))
+		if err := r.Delete(ctx, &old[index]); err != nil && !apierrors.IsNotFound(err) {
+			log.Error("Failed to delete revision", zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateCurrentRevision marks the update revision as the current one once its rollout is completed
+func (r *executer) updateCurrentRevision(ctx context.Context, executer *appsv1alpha1.Executer, deployment *appsv1.Deployment, desiredHash string, log *zap.Logger) error {
+	status := &executer.Status
+	if status.CurrentRevision == status.UpdateRevision || rolledBack(executer, desiredHash) || !rolloutCompleted(deployment) {
+		return nil
+	}
+
+	status.CurrentRevision = status.UpdateRevision
+	if err := r.Status().Update(ctx, executer); err != nil {
+		log.Error("Failed to update revision status", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package apps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// newRevisionReconciler returns the reconciler holding the test executer, keeping the given number of old revisions
+func newRevisionReconciler(t *testing.T, historyLimit int32) (*executer, *record.FakeRecorder) {
+	r, recorder := newTestReconciler(t, newTestExecuter())
+	cfg := &Config{}
+	cfg.Revisions.HistoryLimit = historyLimit
+	r.Reload(cfg)
+	return r, recorder
+}
+
+// setImage changes the image of the stored executer as a new generation
+func setImage(t *testing.T, r *executer, image string) {
+	executer := &appsv1alpha1.Executer{}
+	require.NoError(t, r.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "worker"}, executer))
+	executer.Spec.Image, executer.Generation = image, executer.Generation+1
+	require.NoError(t, r.Update(context.Background(), executer))
+}
+
+// reconcileRevisions runs ReconcileRevisions on the stored executer and returns it with the images of its revisions
+func reconcileRevisions(t *testing.T, r *executer) (bool, *appsv1alpha1.Executer, map[int64]string) {
+	ctx := context.Background()
+
+	executer := &appsv1alpha1.Executer{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: "worker"}, executer))
+
+	restored, err := r.ReconcileRevisions(ctx, executer, zap.NewNop())
+	require.NoError(t, err)
+
+	revisions, err := Revisions(ctx, r.Client, executer)
+	require.NoError(t, err)
+
+	images := make(map[int64]string, len(revisions))
+	for index := range revisions {
+		spec, err := RevisionSpec(&revisions[index])
+		require.NoError(t, err)
+		images[revisions[index].Revision] = spec.Image
+	}
+	return restored, executer, images
+}
+
+func TestReconcileRevisionsHistory(t *testing.T) {
+	r, recorder := newRevisionReconciler(t, 10)
+
+	restored, executer, images := reconcileRevisions(t, r)
+	assert.False(t, restored)
+	assert.Equal(t, map[int64]string{1: "worker:v1"}, images)
+	assert.Equal(t, []string{"Normal RevisionCreated"}, recordedEvents(recorder))
+	v1 := executer.Status.UpdateRevision
+
+	// an unchanged spec keeps its revision
+	_, executer, images = reconcileRevisions(t, r)
+	assert.Equal(t, map[int64]string{1: "worker:v1"}, images)
+	assert.Equal(t, v1, executer.Status.UpdateRevision)
+	assert.Empty(t, recordedEvents(recorder))
+
+	setImage(t, r, "worker:v2")
+	_, executer, images = reconcileRevisions(t, r)
+	assert.Equal(t, map[int64]string{1: "worker:v1", 2: "worker:v2"}, images)
+	assert.NotEqual(t, v1, executer.Status.UpdateRevision)
+	assert.Equal(t, []string{"Normal RevisionCreated"}, recordedEvents(recorder))
+
+	// changing the spec back reuses its revision as the latest one
+	setImage(t, r, "worker:v1")
+	_, executer, images = reconcileRevisions(t, r)
+	assert.Equal(t, map[int64]string{2: "worker:v2", 3: "worker:v1"}, images)
+	assert.Equal(t, v1, executer.Status.UpdateRevision)
+	assert.Empty(t, recordedEvents(recorder))
+}
+
+func TestReconcileRevisionsStaleCache(t *testing.T) {
+	live, _ := newRevisionReconciler(t, 10)
+	reconcileRevisions(t, live)
+
+	// the cache hasn't observed the revision created by the previous reconciliation yet
+	stale, recorder := newRevisionReconciler(t, 10)
+	stale.apiReader = live.Client
+
+	executer := &appsv1alpha1.Executer{}
+	require.NoError(t, live.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "worker"}, executer))
+	_, err := stale.ReconcileRevisions(context.Background(), executer, zap.NewNop())
+	require.NoError(t, err)
+	assert.Empty(t, recordedEvents(recorder))
+
+	revisions, err := Revisions(context.Background(), stale.Client, executer)
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+}
+
+func TestReconcileRevisionsPrune(t *testing.T) {
+	r, _ := newRevisionReconciler(t, 1)
+
+	// the first revision stays as the current one while the others are rolled out
+	_, executer, _ := reconcileRevisions(t, r)
+	executer.Status.CurrentRevision = executer.Status.UpdateRevision
+	require.NoError(t, r.Status().Update(context.Background(), executer))
+
+	var images map[int64]string
+	for _, image := range []string{"worker:v2", "worker:v3", "worker:v4"} {
+		setImage(t, r, image)
+		_, _, images = reconcileRevisions(t, r)
+	}
+
+	// the current and update revisions are kept beyond the history limit
+	assert.Equal(t, map[int64]string{1: "worker:v1", 3: "worker:v3", 4: "worker:v4"}, images)
+}
+
+func TestRestoreRevision(t *testing.T) {
+	tests := []struct {
+		name     string
+		revision int64
+		image    string
+		events   []string
+	}{
+		{
+			name:     "found",
+			revision: 1,
+			image:    "worker:v1",
+			events:   []string{"Normal RevisionRestored"},
+		},
+		{
+			name:     "missing",
+			revision: 5,
+			image:    "worker:v2",
+			events:   []string{"Warning RevisionFailed"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, recorder := newRevisionReconciler(t, 10)
+			reconcileRevisions(t, r)
+			setImage(t, r, "worker:v2")
+			reconcileRevisions(t, r)
+			recordedEvents(recorder)
+
+			executer := &appsv1alpha1.Executer{}
+			require.NoError(t, r.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "worker"}, executer))
+			executer.Spec.RollbackTo = pointer.Int64(test.revision)
+			require.NoError(t, r.Update(context.Background(), executer))
+
+			restored, executer, images := reconcileRevisions(t, r)
+			assert.True(t, restored)
+			assert.Equal(t, map[int64]string{1: "worker:v1", 2: "worker:v2"}, images)
+			assert.Equal(t, test.events, recordedEvents(recorder))
+
+			stored := &appsv1alpha1.Executer{}
+			require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(executer), stored))
+			assert.Nil(t, stored.Spec.RollbackTo)
+			assert.Equal(t, test.image, stored.Spec.Image)
+		})
+	}
+}
+
+func TestUpdateCurrentRevision(t *testing.T) {
+	completed := appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+
+	tests := []struct {
+		name       string
+		status     appsv1.DeploymentStatus
+		rolledBack bool
+		current    string
+	}{
+		{
+			name:    "rolling out",
+			status:  appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2},
+			current: "worker-v1",
+		},
+		{
+			name:    "completed",
+			status:  completed,
+			current: "worker-v2",
+		},
+		{
+			name:       "rolled back",
+			status:     completed,
+			rolledBack: true,
+			current:    "worker-v1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executer := newTestExecuter()
+			executer.Status.CurrentRevision, executer.Status.UpdateRevision = "worker-v1", "worker-v2"
+			if test.rolledBack {
+				executer.Status.FailedTemplate = "failed"
+				executer.Status.LastGoodTemplate = deploymentTemplate(executer, &Config{}).Spec.Template.DeepCopy()
+				executer.Status.Conditions = []metav1.Condition{{
+					Type:               appsv1alpha1.ConditionRolledBack,
+					Status:             metav1.ConditionTrue,
+					Reason:             RollbackProgressDeadline,
+					ObservedGeneration: executer.Generation,
+				}}
+			}
+
+			r, recorder := newTestReconciler(t, executer)
+			deployment := deploymentTemplate(executer, &Config{})
+			deployment.Status = test.status
+
+			require.NoError(t, r.updateCurrentRevision(context.Background(), executer, deployment, "failed", zap.NewNop()))
+
+			stored := &appsv1alpha1.Executer{}
+			require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(executer), stored))
+			assert.Equal(t, test.current, stored.Status.CurrentRevision)
+			assert.Empty(t, recordedEvents(recorder))
+		})
+	}
+}
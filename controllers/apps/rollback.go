@@ -0,0 +1,143 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/controllers/metrics"
+)
+
+// revisionAnnotation is set by the deployment controller on the deployments and their replica sets
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// Reasons of the RolledBack condition
+const (
+	RollbackProgressDeadline = "ProgressDeadlineExceeded"
+	RollbackRestartThreshold = "RestartThresholdExceeded"
+)
+
+// rolledBack reports whether the desired template, identified by its hash, is reverted to the last good one.
+// It's kept reverted until the executer or its referenced configs are changed.
+func rolledBack(executer *appsv1alpha1.Executer, desiredHash string) bool {
+	condition := meta.FindStatusCondition(executer.Status.Conditions, appsv1alpha1.ConditionRolledBack)
+	return condition != nil && condition.Status == metav1.ConditionTrue && condition.ObservedGeneration == executer.Generation &&
+		executer.Status.FailedTemplate == desiredHash && executer.Status.LastGoodTemplate != nil
+}
+
+// rolloutCompleted reports whether all the replicas of the deployment run its template and are available
+func rolloutCompleted(deployment *appsv1.Deployment) bool {
+	replicas, status := *deployment.Spec.Replicas, deployment.Status
+	return status.ObservedGeneration == deployment.Generation && status.Replicas == replicas &&
+		status.UpdatedReplicas == replicas && status.AvailableReplicas == replicas
+}
+
+// ReconcileRollback records the template of the completed rollouts as the last good one,
+// and reverts the deployment to it if the rollout of the desired template, identified by its hash, fails
+func (r *executer) ReconcileRollback(ctx context.Context, executer *appsv1alpha1.Executer, deployment *appsv1.Deployment, desiredHash string, log *zap.Logger) error {
+	lastGood := executer.Status.LastGoodTemplate
+	good := lastGood != nil && equality.Semantic.DeepEqual(*lastGood, deployment.Spec.Template)
+
+	if rolloutCompleted(deployment) {
+		// the condition of the previous templates is stale once a rollout is completed
+		stale := meta.FindStatusCondition(executer.Status.Conditions, appsv1alpha1.ConditionRolledBack) != nil
+		if rolledBack(executer, desiredHash) || (good && !stale) {
+			return nil
+		}
+
+		executer.Status.LastGoodTemplate = deployment.Spec.Template.DeepCopy()
+		meta.RemoveStatusCondition(&executer.Status.Conditions, appsv1alpha1.ConditionRolledBack)
+		if err := r.Status().Update(ctx, executer); err != nil {
+			log.Error("Failed to update last good template", zap.Error(err))
+			return err
+		}
+		return nil
+	}
+
+	cfg := r.config.Load()
+	if !cfg.Rollback.Enabled || lastGood == nil || good || rolledBack(executer, desiredHash) {
+		return nil
+	}
+
+	reason, message, err := r.rolloutFailure(ctx, deployment, cfg)
+	if err != nil || reason == "" {
+		return err
+	}
+
+	log.Info("Rolling back executer's deployment", zap.String("reason", reason), zap.String("message", message))
+	copyTemplate(&deployment.Spec.Template, lastGood)
+	if err := r.Update(ctx, deployment); err != nil {
+		log.Error("Failed to roll back Deployment", zap.Error(err))
+		r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonDeploymentFailed, "Failed to roll back deployment %s: %v", deployment.Name, err)
+		return err
+	}
+
+	executer.Status.FailedTemplate = desiredHash
+	meta.SetStatusCondition(&executer.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionRolledBack,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: executer.Generation,
+	})
+	if err := r.Status().Update(ctx, executer); err != nil {
+		log.Error("Failed to update rollback condition", zap.Error(err))
+		return err
+	}
+
+	metrics.ObserveRollback(executer, reason)
+	r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonRolledBack, "Rolled back deployment %s to the last good template: %s", deployment.Name, message)
+	return nil
+}
+
+// rolloutFailure returns the reason and message of the failure of the deployment's rollout, empty if it isn't failed
+func (r *executer) rolloutFailure(ctx context.Context, deployment *appsv1.Deployment, cfg *Config) (string, string, error) {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == RollbackProgressDeadline {
+			return RollbackProgressDeadline, condition.Message, nil
+		}
+	}
+
+	if cfg.Rollback.RestartThreshold == 0 {
+		return "", "", nil
+	}
+
+	// the pods of the new template are the ones of the replica set with the same revision of the deployment
+	replicaSets := &appsv1.ReplicaSetList{}
+	if err := r.List(ctx, replicaSets, client.InNamespace(deployment.Namespace), client.MatchingLabels(deployment.Spec.Selector.MatchLabels)); err != nil {
+		return "", "", err
+	}
+
+	for index := range replicaSets.Items {
+		replicaSet := &replicaSets.Items[index]
+		if !metav1.IsControlledBy(replicaSet, deployment) || replicaSet.Annotations[revisionAnnotation] != deployment.Annotations[revisionAnnotation] {
+			continue
+		}
+
+		pods := &corev1.PodList{}
+		if err := r.List(ctx, pods, client.InNamespace(deployment.Namespace), client.MatchingLabels(replicaSet.Spec.Selector.MatchLabels)); err != nil {
+			return "", "", err
+		}
+
+		for _, pod := range pods.Items {
+			for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+				for _, status := range statuses {
+					if status.RestartCount >= cfg.Rollback.RestartThreshold {
+						message := fmt.Sprintf("container %s of pod %s restarted %d times", status.Name, pod.Name, status.RestartCount)
+						return RollbackRestartThreshold, message, nil
+					}
+				}
+			}
+		}
+	}
+
+	return "", "", nil
+}
@@ -0,0 +1,166 @@
+package apps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// newRollingExecuter returns the executer whose spec changed the image of its deployment, with rollbacks enabled
+func newRollingExecuter(t *testing.T, threshold int32) (*executer, *appsv1alpha1.Executer, *corev1.ConfigMap) {
+	ctx := context.Background()
+	executer := newTestExecuter()
+	settings := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+		Data:       map[string]string{"level": "info"},
+	}
+
+	r, _ := newTestReconciler(t, executer, settings)
+	cfg := &Config{}
+	cfg.Rollback.Enabled, cfg.Rollback.RestartThreshold = true, threshold
+	r.Reload(cfg)
+
+	deployment := desiredDeployment(t, r, executer)
+	require.NoError(t, r.Create(ctx, deployment))
+
+	executer.Status.LastGoodTemplate = deployment.Spec.Template.DeepCopy()
+	require.NoError(t, r.Status().Update(ctx, executer))
+
+	executer.Spec.Image, executer.Generation = "worker:v2", 2
+	require.NoError(t, r.Update(ctx, executer))
+	return r, executer, settings
+}
+
+// setDeploymentStatus replaces the status of the executer's deployment
+func setDeploymentStatus(t *testing.T, r *executer, executer *appsv1alpha1.Executer, status appsv1.DeploymentStatus) {
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(executer), deployment))
+	deployment.Status = status
+	require.NoError(t, r.Status().Update(context.Background(), deployment))
+}
+
+func TestReconcileRollbackCompleted(t *testing.T) {
+	r, executer, _ := newRollingExecuter(t, 0)
+
+	_, deployment, _ := reconcileDeployment(t, r, executer.Name)
+	assert.Equal(t, "worker:v2", deployment.Spec.Template.Spec.Containers[0].Image)
+
+	setDeploymentStatus(t, r, executer, appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2})
+	executer, deployment, _ = reconcileDeployment(t, r, executer.Name)
+	require.NotNil(t, executer.Status.LastGoodTemplate)
+	assert.Equal(t, deployment.Spec.Template, *executer.Status.LastGoodTemplate)
+	assert.Nil(t, meta.FindStatusCondition(executer.Status.Conditions, appsv1alpha1.ConditionRolledBack))
+}
+
+func TestReconcileRollbackProgressDeadline(t *testing.T) {
+	ctx := context.Background()
+	r, executer, settings := newRollingExecuter(t, 0)
+	recorder := r.recorder.(*record.FakeRecorder)
+
+	reconcileDeployment(t, r, executer.Name)
+	assert.Equal(t, []string{"Normal DeploymentUpdated"}, recordedEvents(recorder))
+
+	setDeploymentStatus(t, r, executer, appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: RollbackProgressDeadline,
+	}}})
+
+	executer, deployment, _ := reconcileDeployment(t, r, executer.Name)
+	assert.Equal(t, "worker:v1", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, TrackLabels(executer, TrackStable), deployment.Spec.Template.Labels)
+	assert.Equal(t, []string{"Warning RolledBack"}, recordedEvents(recorder))
+
+	condition := meta.FindStatusCondition(executer.Status.Conditions, appsv1alpha1.ConditionRolledBack)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, RollbackProgressDeadline, condition.Reason)
+
+	// the failed template is kept reverted
+	_, deployment, _ = reconcileDeployment(t, r, executer.Name)
+	assert.Equal(t, "worker:v1", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.Empty(t, recordedEvents(recorder))
+
+	// until its referenced configs are changed
+	settings.Data["level"] = "debug"
+	require.NoError(t, r.Update(ctx, settings))
+
+	_, deployment, _ = reconcileDeployment(t, r, executer.Name)
+	assert.Equal(t, "worker:v2", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, []string{"Normal DeploymentUpdated"}, recordedEvents(recorder))
+}
+
+func TestReconcileRollbackRestartThreshold(t *testing.T) {
+	tests := []struct {
+		name       string
+		threshold  int32
+		restarts   int32
+		rolledBack bool
+	}{
+		{name: "below the threshold", threshold: 3, restarts: 2},
+		{name: "reaching the threshold", threshold: 3, restarts: 3, rolledBack: true},
+		{name: "disabled threshold", threshold: 0, restarts: 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			r, executer, _ := newRollingExecuter(t, test.threshold)
+			_, deployment, _ := reconcileDeployment(t, r, executer.Name)
+
+			deployment.Annotations = map[string]string{revisionAnnotation: "2"}
+			require.NoError(t, r.Update(ctx, deployment))
+
+			selector := TrackLabels(executer, TrackStable)
+			selector["pod-template-hash"] = "7c9d"
+			replicaSet := &appsv1.ReplicaSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "worker-7c9d", Namespace: "default", Labels: selector,
+					Annotations:     map[string]string{revisionAnnotation: "2"},
+					OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+				},
+				Spec: appsv1.ReplicaSetSpec{Selector: &metav1.LabelSelector{MatchLabels: selector}},
+			}
+			require.NoError(t, r.Create(ctx, replicaSet))
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker-7c9d-x2x4q", Namespace: "default", Labels: selector},
+				Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+					{Name: executer.Name, RestartCount: test.restarts},
+				}},
+			}
+			require.NoError(t, r.Create(ctx, pod))
+
+			executer, deployment, _ = reconcileDeployment(t, r, executer.Name)
+			assert.Equal(t, test.rolledBack, deployment.Spec.Template.Spec.Containers[0].Image == "worker:v1")
+
+			condition := meta.FindStatusCondition(executer.Status.Conditions, appsv1alpha1.ConditionRolledBack)
+			assert.Equal(t, test.rolledBack, condition != nil && condition.Reason == RollbackRestartThreshold)
+		})
+	}
+}
+
+func TestReconcileRollbackDisabled(t *testing.T) {
+	r, executer, _ := newRollingExecuter(t, 0)
+	r.Reload(&Config{})
+
+	reconcileDeployment(t, r, executer.Name)
+	setDeploymentStatus(t, r, executer, appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: RollbackProgressDeadline,
+	}}})
+
+	_, deployment, _ := reconcileDeployment(t, r, executer.Name)
+	assert.Equal(t, "worker:v2", deployment.Spec.Template.Spec.Containers[0].Image)
+}
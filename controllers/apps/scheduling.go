@@ -0,0 +1,48 @@
+package apps
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// zoneTopologyKey is the well-known label of the nodes' zone
+const zoneTopologyKey = "topology.kubernetes.io/zone"
+
+// setScheduling sets the scheduling fields of the executer on the pod spec
+func setScheduling(executer *appsv1alpha1.Executer, spec *corev1.PodSpec) {
+	spec.NodeSelector = executer.Spec.NodeSelector
+	spec.Tolerations = executer.Spec.Tolerations
+	spec.Affinity = executer.Spec.Affinity
+	spec.PriorityClassName = executer.Spec.PriorityClassName
+	spec.TopologySpreadConstraints = executer.Spec.TopologySpreadConstraints
+
+	if executer.Spec.SpreadAcrossZones {
+		constraints := append([]corev1.TopologySpreadConstraint{}, spec.TopologySpreadConstraints...)
+		spec.TopologySpreadConstraints = append(constraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       zoneTopologyKey,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     &metav1.LabelSelector{MatchLabels: Labels(executer)},
+		})
+	}
+}
+
+// schedulingChanged compares the scheduling fields strictly, as they're not defaulted by the api-server
+func schedulingChanged(found, desired *corev1.PodSpec) bool {
+	return !equality.Semantic.DeepEqual(found.NodeSelector, desired.NodeSelector) ||
+		!equality.Semantic.DeepEqual(found.Tolerations, desired.Tolerations) ||
+		!equality.Semantic.DeepEqual(found.Affinity, desired.Affinity) ||
+		!equality.Semantic.DeepEqual(found.TopologySpreadConstraints, desired.TopologySpreadConstraints) ||
+		found.PriorityClassName != desired.PriorityClassName
+}
+
+func copyScheduling(found, desired *corev1.PodSpec) {
+	found.NodeSelector = desired.NodeSelector
+	found.Tolerations = desired.Tolerations
+	found.Affinity = desired.Affinity
+	found.TopologySpreadConstraints = desired.TopologySpreadConstraints
+	found.PriorityClassName = desired.PriorityClassName
+}
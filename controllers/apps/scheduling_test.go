@@ -0,0 +1,78 @@
+package apps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetScheduling(t *testing.T) {
+	hostSpread := corev1.TopologySpreadConstraint{
+		MaxSkew:           2,
+		TopologyKey:       "kubernetes.io/hostname",
+		WhenUnsatisfiable: corev1.DoNotSchedule,
+		LabelSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": "worker"}},
+	}
+	zoneSpread := corev1.TopologySpreadConstraint{
+		MaxSkew:           1,
+		TopologyKey:       zoneTopologyKey,
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+		LabelSelector:     &metav1.LabelSelector{MatchLabels: Labels(newTestExecuter())},
+	}
+
+	tests := []struct {
+		name              string
+		constraints       []corev1.TopologySpreadConstraint
+		spreadAcrossZones bool
+		expected          []corev1.TopologySpreadConstraint
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:        "user constraints",
+			constraints: []corev1.TopologySpreadConstraint{hostSpread},
+			expected:    []corev1.TopologySpreadConstraint{hostSpread},
+		},
+		{
+			name:              "spread across zones",
+			spreadAcrossZones: true,
+			expected:          []corev1.TopologySpreadConstraint{zoneSpread},
+		},
+		{
+			name:              "spread across zones after the user constraints",
+			constraints:       []corev1.TopologySpreadConstraint{hostSpread},
+			spreadAcrossZones: true,
+			expected:          []corev1.TopologySpreadConstraint{hostSpread, zoneSpread},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			executer := newTestExecuter()
+			executer.Spec.NodeSelector = map[string]string{"pool": "workers"}
+			executer.Spec.PriorityClassName = "high"
+			executer.Spec.SpreadAcrossZones = test.spreadAcrossZones
+
+			// the user's slice has spare capacity, which must not be taken by the zone constraint
+			if test.constraints != nil {
+				executer.Spec.TopologySpreadConstraints = make([]corev1.TopologySpreadConstraint, len(test.constraints), len(test.constraints)+1)
+				copy(executer.Spec.TopologySpreadConstraints, test.constraints)
+			}
+
+			spec := &corev1.PodSpec{}
+			setScheduling(executer, spec)
+
+			assert.Equal(t, test.expected, spec.TopologySpreadConstraints)
+			user := executer.Spec.TopologySpreadConstraints
+			assert.Equal(t, test.constraints, user)
+			for _, spare := range user[len(user):cap(user)] {
+				assert.Empty(t, spare, "the spare capacity of the user's constraints is written")
+			}
+			assert.Equal(t, executer.Spec.NodeSelector, spec.NodeSelector)
+			assert.Equal(t, "high", spec.PriorityClassName)
+		})
+	}
+}
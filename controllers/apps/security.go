@@ -0,0 +1,76 @@
+package apps
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/utils/pointer"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// DefaultsChecksumAnnotation holds the checksum of the security defaults on the pod template,
+// so their reloads are told apart from the out of band changes of the deployment
+const DefaultsChecksumAnnotation = "apps.mohammadne.me/defaults-checksum"
+
+// defaultsChecksum hashes the security defaults applied to the pod template
+func defaultsChecksum(cfg *Config) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%+v", cfg.Security.Defaults)))
+	return hex.EncodeToString(hash[:])
+}
+
+// podSecurityContext merges the pod security context of the executer on top of the secure defaults,
+// it's never nil as the api-server defaults it to an empty one
+func podSecurityContext(executer *appsv1alpha1.Executer, cfg *Config) *corev1.PodSecurityContext {
+	context := executer.Spec.SecurityContext.DeepCopy()
+	if context == nil {
+		context = &corev1.PodSecurityContext{}
+	}
+
+	defaults := cfg.Security.Defaults
+	if defaults.RunAsNonRoot && context.RunAsNonRoot == nil {
+		context.RunAsNonRoot = pointer.Bool(true)
+	}
+
+	if defaults.SeccompRuntimeDefault && context.SeccompProfile == nil {
+		context.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+
+	return context
+}
+
+// containerSecurityContext merges the security context of a container on top of the secure defaults
+func containerSecurityContext(securityContext *corev1.SecurityContext, cfg *Config) *corev1.SecurityContext {
+	context := securityContext.DeepCopy()
+	if context == nil {
+		context = &corev1.SecurityContext{}
+	}
+
+	defaults := cfg.Security.Defaults
+	if defaults.ReadOnlyRootFilesystem && context.ReadOnlyRootFilesystem == nil {
+		context.ReadOnlyRootFilesystem = pointer.Bool(true)
+	}
+
+	if defaults.DropAllCapabilities && context.Capabilities == nil {
+		context.Capabilities = &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}}
+	}
+
+	if equality.Semantic.DeepEqual(context, &corev1.SecurityContext{}) {
+		return nil
+	}
+	return context
+}
+
+// securityChanged compares the pod-level security fields strictly, the ones of the containers are compared with them
+func securityChanged(found, desired *corev1.PodSpec) bool {
+	return !equality.Semantic.DeepEqual(found.SecurityContext, desired.SecurityContext) ||
+		found.ServiceAccountName != desired.ServiceAccountName
+}
+
+func copySecurity(found, desired *corev1.PodSpec) {
+	found.SecurityContext = desired.SecurityContext
+	found.ServiceAccountName = desired.ServiceAccountName
+}
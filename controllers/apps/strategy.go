@@ -0,0 +1,327 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/controllers/metrics"
+)
+
+// TrackLabel tells the stable pods from the canary ones, both are selected by the labels of the executer
+const TrackLabel = "apps.mohammadne.me/track"
+
+// Tracks of the pods of the executer
+const (
+	TrackStable = "stable"
+	TrackCanary = "canary"
+)
+
+// TrackLabels are the labels of the executer's pods of the track, the deployment of each track only selects its own pods
+func TrackLabels(executer *appsv1alpha1.Executer, track string) map[string]string {
+	labels := Labels(executer)
+	labels[TrackLabel] = track
+	return labels
+}
+
+// canaryRequeue is the interval of checking the canary pods until they're ready
+const canaryRequeue = 10 * time.Second
+
+// canaryProgressDeadline is the default time the canary pods can make no progress before the canary is failed
+const canaryProgressDeadline = 10 * time.Minute
+
+// CanaryProgressDeadline is the reason of the Canary condition of the failed canaries
+const CanaryProgressDeadline = "ProgressDeadlineExceeded"
+
+// CanaryName is the name of the deployment of the canary pods
+func CanaryName(executer *appsv1alpha1.Executer) string {
+	return executer.Name + "-canary"
+}
+
+// deploymentStrategy converts the strategy of the executer, the canaries are promoted by rolling updates
+func deploymentStrategy(executer *appsv1alpha1.Executer) appsv1.DeploymentStrategy {
+	strategy := executer.Spec.Strategy
+	if strategy == nil {
+		return appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType}
+	}
+
+	if strategy.Type == appsv1alpha1.StrategyRecreate {
+		return appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	}
+	return appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType, RollingUpdate: strategy.RollingUpdate}
+}
+
+// strategyChanged compares only the fields set by the controller, the parameters of the rolling updates are defaulted
+func strategyChanged(found, desired *appsv1.Deployment) bool {
+	return !equality.Semantic.DeepDerivative(desired.Spec.Strategy, found.Spec.Strategy)
+}
+
+func canaryStrategy(executer *appsv1alpha1.Executer) bool {
+	return executer.Spec.Strategy != nil && executer.Spec.Strategy.Type == appsv1alpha1.StrategyCanary
+}
+
+// canaryDeployment returns the deployment of the canary pods running the desired template
+func canaryDeployment(executer *appsv1alpha1.Executer, desired *appsv1.Deployment) *appsv1.Deployment {
+	replicas, canary := int32(1), executer.Spec.Strategy.Canary
+	if canary != nil && canary.Replicas > 0 {
+		replicas = canary.Replicas
+	}
+
+	deadline := canaryProgressDeadline
+	if canary != nil && canary.ProgressDeadline.Duration > 0 {
+		deadline = canary.ProgressDeadline.Duration
+	}
+
+	deployment := desired.DeepCopy()
+	deployment.Name = CanaryName(executer)
+	deployment.Spec.Replicas = pointer.Int32(replicas)
+	deployment.Spec.ProgressDeadlineSeconds = pointer.Int32(int32(deadline / time.Second))
+	deployment.Spec.Selector.MatchLabels = TrackLabels(executer, TrackCanary)
+	deployment.Spec.Template.Labels = TrackLabels(executer, TrackCanary)
+	return deployment
+}
+
+// canaryCondition reports whether all the canary pods are ready, the time of its transition is the start of the gate
+func canaryCondition(executer *appsv1alpha1.Executer, canary *appsv1.Deployment) metav1.Condition {
+	condition := metav1.Condition{Type: appsv1alpha1.ConditionCanary, ObservedGeneration: executer.Generation}
+
+	replicas, status := *canary.Spec.Replicas, canary.Status
+	condition.Message = fmt.Sprintf("%d/%d canary pods of %s are ready", status.ReadyReplicas, replicas, canary.Name)
+	if status.ObservedGeneration == canary.Generation && status.Replicas == replicas &&
+		status.UpdatedReplicas == replicas && status.ReadyReplicas == replicas {
+		condition.Status, condition.Reason = metav1.ConditionTrue, "Ready"
+	} else {
+		condition.Status, condition.Reason = metav1.ConditionFalse, "Progressing"
+	}
+
+	return condition
+}
+
+// canaryFailed reports whether the canary of the desired template is failed in the current generation
+func canaryFailed(executer *appsv1alpha1.Executer, desired *appsv1.Deployment) bool {
+	condition := meta.FindStatusCondition(executer.Status.Conditions, appsv1alpha1.ConditionCanary)
+	return condition != nil && condition.Reason == CanaryProgressDeadline && condition.ObservedGeneration == executer.Generation &&
+		executer.Status.FailedTemplate == templateHash(&desired.Spec.Template)
+}
+
+// progressDeadlineExceeded reports whether the deployment made no progress within its deadline
+func progressDeadlineExceeded(deployment *appsv1.Deployment) bool {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == CanaryProgressDeadline {
+			return true
+		}
+	}
+	return false
+}
+
+// ReconcileCanary runs the desired template in the canary deployment if it's changed, and reports whether it can be
+// applied to the executer's deployment. The canary is removed once it's promoted, failed or isn't wanted anymore,
+// the failed template isn't run again until the executer or its referenced configs are changed.
+func (r *executer) ReconcileCanary(ctx context.Context, executer *appsv1alpha1.Executer, desired *appsv1.Deployment, changed bool, log *zap.Logger) (bool, ctrl.Result, error) {
+	key := types.NamespacedName{Namespace: executer.Namespace, Name: CanaryName(executer)}
+
+	found := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, found); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error("Failed to get canary Deployment", zap.Error(err))
+			return false, ctrl.Result{}, err
+		}
+		found = nil
+	}
+
+	if !changed || !canaryStrategy(executer) {
+		if err := r.deleteCanary(ctx, executer, found, log); err != nil {
+			return false, ctrl.Result{}, err
+		}
+		return true, ctrl.Result{}, nil
+	}
+
+	if canaryFailed(executer, desired) {
+		return false, ctrl.Result{}, nil
+	}
+
+	canary := canaryDeployment(executer, desired)
+	switch {
+	case found == nil:
+		log.Info("Creating the canary Deployment")
+		if err := r.Create(ctx, canary); err != nil {
+			log.Error("Failed to create canary Deployment", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonCanaryFailed, "Failed to create canary deployment %s: %v", canary.Name, err)
+			return false, ctrl.Result{}, err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonCanaryStarted, "Started canary deployment %s with %d replicas", canary.Name, *canary.Spec.Replicas)
+		found = canary
+
+	case !metav1.IsControlledBy(found, executer):
+		err := fmt.Errorf("Deployment %s already exists and isn't owned by the executer", found.Name)
+		log.Error("Failed to update canary Deployment", zap.Error(err))
+		r.recorder.Event(executer, corev1.EventTypeWarning, ReasonCanaryFailed, err.Error())
+		return false, ctrl.Result{}, err
+
+	case *found.Spec.Replicas != *canary.Spec.Replicas || templateChanged(found, canary) ||
+		!equality.Semantic.DeepEqual(found.Spec.ProgressDeadlineSeconds, canary.Spec.ProgressDeadlineSeconds):
+		log.Info("Updating the canary Deployment")
+		found.Spec.Replicas = canary.Spec.Replicas
+		found.Spec.ProgressDeadlineSeconds = canary.Spec.ProgressDeadlineSeconds
+		found.Spec.Template = canary.Spec.Template
+		if err := r.Update(ctx, found); err != nil {
+			log.Error("Failed to update canary Deployment", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonCanaryFailed, "Failed to update canary deployment %s: %v", found.Name, err)
+			return false, ctrl.Result{}, err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonCanaryStarted, "Restarted canary deployment %s with the latest template", found.Name)
+	}
+
+	if progressDeadlineExceeded(found) {
+		return false, ctrl.Result{}, r.failCanary(ctx, executer, desired, found, log)
+	}
+
+	if err := r.updateCondition(ctx, executer, canaryCondition(executer, found)); err != nil {
+		log.Error("Failed to update canary condition", zap.Error(err))
+		return false, ctrl.Result{}, err
+	}
+
+	condition := meta.FindStatusCondition(executer.Status.Conditions, appsv1alpha1.ConditionCanary)
+	if condition.Status != metav1.ConditionTrue {
+		return false, ctrl.Result{RequeueAfter: canaryRequeue}, nil
+	}
+
+	var duration time.Duration
+	if canary := executer.Spec.Strategy.Canary; canary != nil {
+		duration = canary.Duration.Duration
+	}
+	if remaining := duration - time.Since(condition.LastTransitionTime.Time); remaining > 0 {
+		return false, ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	if err := r.deleteCanary(ctx, executer, found, log); err != nil {
+		return false, ctrl.Result{}, err
+	}
+
+	r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonCanaryPromoted, "Promoted canary deployment %s after being ready for %s", found.Name, duration)
+	return true, ctrl.Result{}, nil
+}
+
+// failCanary removes the canary deployment which made no progress within its deadline, and records its template as failed
+func (r *executer) failCanary(ctx context.Context, executer *appsv1alpha1.Executer, desired, found *appsv1.Deployment, log *zap.Logger) error {
+	log.Info("Deleting the failed canary Deployment")
+	if err := r.Delete(ctx, found); err != nil && !apierrors.IsNotFound(err) {
+		log.Error("Failed to delete canary Deployment", zap.Error(err))
+		return err
+	}
+
+	message := fmt.Sprintf("canary pods of %s made no progress within %ds", found.Name, *found.Spec.ProgressDeadlineSeconds)
+	previous := executer.Status.Phase
+	executer.Status.Phase = appsv1alpha1.PhaseFailed
+	executer.Status.FailedTemplate = templateHash(&desired.Spec.Template)
+	meta.SetStatusCondition(&executer.Status.Conditions, metav1.Condition{
+		Type:               appsv1alpha1.ConditionCanary,
+		Status:             metav1.ConditionFalse,
+		Reason:             CanaryProgressDeadline,
+		Message:            message,
+		ObservedGeneration: executer.Generation,
+	})
+	if err := r.Status().Update(ctx, executer); err != nil {
+		log.Error("Failed to update canary condition", zap.Error(err))
+		return err
+	}
+
+	metrics.ObservePhase(executer, previous)
+	r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonCanaryFailed, "Failed canary deployment %s: %s", found.Name, message)
+	return nil
+}
+
+// deleteCanary removes the canary deployment if it's owned by the executer and its condition
+func (r *executer) deleteCanary(ctx context.Context, executer *appsv1alpha1.Executer, found *appsv1.Deployment, log *zap.Logger) error {
+	if found != nil && metav1.IsControlledBy(found, executer) {
+		log.Info("Deleting the canary Deployment")
+		if err := r.Delete(ctx, found); err != nil && !apierrors.IsNotFound(err) {
+			log.Error("Failed to delete canary Deployment", zap.Error(err))
+			return err
+		}
+	}
+
+	if err := r.updateCondition(ctx, executer, metav1.Condition{Type: appsv1alpha1.ConditionCanary}); err != nil {
+		log.Error("Failed to remove canary condition", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// migrateDeployment deletes the deployment created before the tracks, as its selector is immutable.
+// Its replica sets are orphaned and keep running until the pods of the recreated deployment are available.
+func (r *executer) migrateDeployment(ctx context.Context, executer *appsv1alpha1.Executer, deployment *appsv1.Deployment, log *zap.Logger) (ctrl.Result, error) {
+	if !metav1.IsControlledBy(deployment, executer) {
+		err := fmt.Errorf("Deployment %s already exists and isn't owned by the executer", deployment.Name)
+		log.Error("Failed to recreate Deployment", zap.Error(err))
+		r.recorder.Event(executer, corev1.EventTypeWarning, ReasonDeploymentFailed, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if deployment.DeletionTimestamp == nil {
+		if err := r.updatePhase(ctx, executer, appsv1alpha1.PhaseUpdating); err != nil {
+			log.Error("Failed to update deployment state", zap.Error(err))
+			return ctrl.Result{}, err
+		}
+
+		log.Info("Recreating the Deployment with the track selector")
+		if err := r.Delete(ctx, deployment, client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil && !apierrors.IsNotFound(err) {
+			log.Error("Failed to delete Deployment", zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonDeploymentFailed, "Failed to recreate deployment %s: %v", deployment.Name, err)
+			return ctrl.Result{}, err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonDeploymentMigrated,
+			"Recreating deployment %s with the track selector, its pods are kept until the new ones are available", deployment.Name)
+	}
+
+	// the deployment is created again once its replica sets are orphaned
+	return ctrl.Result{RequeueAfter: time.Second}, nil
+}
+
+// deleteOrphans removes the replica sets orphaned by migrateDeployment once the rollout of the recreated deployment is completed
+func (r *executer) deleteOrphans(ctx context.Context, executer *appsv1alpha1.Executer, deployment *appsv1.Deployment, log *zap.Logger) error {
+	if !rolloutCompleted(deployment) {
+		return nil
+	}
+
+	replicaSets := &appsv1.ReplicaSetList{}
+	if err := r.List(ctx, replicaSets, client.InNamespace(executer.Namespace), client.MatchingLabels(Labels(executer))); err != nil {
+		log.Error("Failed to list ReplicaSets", zap.Error(err))
+		return err
+	}
+
+	for index := range replicaSets.Items {
+		replicaSet := &replicaSets.Items[index]
+		if metav1.GetControllerOf(replicaSet) != nil || replicaSet.Spec.Selector.MatchLabels[TrackLabel] != "" {
+			continue
+		}
+
+		log.Info("Deleting the orphaned ReplicaSet", zap.String("replicaSet", replicaSet.Name))
+		if err := r.Delete(ctx, replicaSet, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			log.Error("Failed to delete orphaned ReplicaSet", zap.String("replicaSet", replicaSet.Name), zap.Error(err))
+			return err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonDeploymentMigrated,
+			"Deleted replica set %s orphaned by the recreation of deployment %s", replicaSet.Name, deployment.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package apps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+func TestCanaryDeploymentTracks(t *testing.T) {
+	executer := newTestExecuter()
+	executer.Spec.Strategy = &appsv1alpha1.Strategy{Type: appsv1alpha1.StrategyCanary}
+
+	stable := deploymentTemplate(executer, &Config{})
+	canary := canaryDeployment(executer, stable)
+
+	selector := func(deployment *appsv1.Deployment) labels.Selector {
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		require.NoError(t, err)
+		return selector
+	}
+
+	assert.True(t, selector(stable).Matches(labels.Set(stable.Spec.Template.Labels)))
+	assert.False(t, selector(stable).Matches(labels.Set(canary.Spec.Template.Labels)))
+	assert.True(t, selector(canary).Matches(labels.Set(canary.Spec.Template.Labels)))
+	assert.False(t, selector(canary).Matches(labels.Set(stable.Spec.Template.Labels)))
+	assert.Equal(t, TrackStable, stable.Spec.Selector.MatchLabels[TrackLabel])
+}
+
+func TestReconcileDeploymentMigration(t *testing.T) {
+	ctx, executer := context.Background(), newTestExecuter()
+	r, recorder := newTestReconciler(t, executer)
+
+	// the deployment and replica set created before the tracks
+	deployment := desiredDeployment(t, r, executer)
+	deployment.Spec.Selector.MatchLabels = Labels(executer)
+	deployment.Spec.Template.Labels = Labels(executer)
+	require.NoError(t, r.Create(ctx, deployment))
+
+	orphan := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-5d4f8", Namespace: "default", Labels: Labels(executer)},
+		Spec:       appsv1.ReplicaSetSpec{Selector: &metav1.LabelSelector{MatchLabels: Labels(executer)}},
+	}
+	require.NoError(t, r.Create(ctx, orphan))
+
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(executer)}
+	result, err := r.ReconcileDeployment(ctx, request, executer, zap.NewNop())
+	require.NoError(t, err)
+	assert.NotZero(t, result.RequeueAfter)
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, request.NamespacedName, &appsv1.Deployment{})))
+	assert.Equal(t, []string{"Normal DeploymentMigrated"}, recordedEvents(recorder))
+
+	_, err = r.ReconcileDeployment(ctx, request, executer, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, r.Get(ctx, request.NamespacedName, deployment))
+	assert.Equal(t, TrackLabels(executer, TrackStable), deployment.Spec.Selector.MatchLabels)
+	assert.Equal(t, []string{"Normal DeploymentCreated"}, recordedEvents(recorder))
+
+	// the orphaned replica set is kept until the pods of the recreated deployment are available
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(orphan), orphan))
+
+	deployment.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 2}
+	require.NoError(t, r.Status().Update(ctx, deployment))
+
+	reconcileDeployment(t, r, executer.Name)
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(orphan), orphan)))
+	assert.Equal(t, []string{"Normal DeploymentMigrated"}, recordedEvents(recorder))
+}
+
+// newCanaryExecuter returns the executer with a canary strategy whose spec changed the image of its deployment
+func newCanaryExecuter(t *testing.T) (*executer, *appsv1alpha1.Executer) {
+	executer := newTestExecuter()
+	executer.Spec.Strategy = &appsv1alpha1.Strategy{Type: appsv1alpha1.StrategyCanary}
+	r, _ := newTestReconciler(t, executer)
+	require.NoError(t, r.Create(context.Background(), desiredDeployment(t, r, executer)))
+
+	executer.Spec.Image, executer.Generation = "worker:v2", 2
+	require.NoError(t, r.Update(context.Background(), executer))
+	return r, executer
+}
+
+func TestReconcileCanaryPromotion(t *testing.T) {
+	ctx := context.Background()
+	r, executer := newCanaryExecuter(t)
+	recorder := r.recorder.(*record.FakeRecorder)
+
+	_, deployment, result := reconcileDeployment(t, r, executer.Name)
+	assert.Equal(t, canaryRequeue, result.RequeueAfter)
+	assert.Equal(t, "worker:v1", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, []string{"Normal CanaryStarted"}, recordedEvents(recorder))
+
+	canary := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: CanaryName(executer)}, canary))
+	assert.Equal(t, "worker:v2", canary.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, int32(canaryProgressDeadline/time.Second), *canary.Spec.ProgressDeadlineSeconds)
+
+	canary.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, ReadyReplicas: 1}
+	require.NoError(t, r.Status().Update(ctx, canary))
+
+	executer, deployment, result = reconcileDeployment(t, r, executer.Name)
+	assert.Zero(t, result)
+	assert.Equal(t, "worker:v2", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, []string{"Normal CanaryPromoted", "Normal DeploymentUpdated"}, recordedEvents(recorder))
+	assert.Nil(t, meta.FindStatusCondition(executer.Status.Conditions, appsv1alpha1.ConditionCanary))
+	assert.Equal(t, executer.Generation, executer.Status.ObservedGeneration)
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(canary), canary)))
+}
+
+func TestReconcileCanaryProgressDeadline(t *testing.T) {
+	ctx := context.Background()
+	r, executer := newCanaryExecuter(t)
+	recorder := r.recorder.(*record.FakeRecorder)
+
+	reconcileDeployment(t, r, executer.Name)
+	recordedEvents(recorder)
+
+	canary := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: CanaryName(executer)}, canary))
+	canary.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: CanaryProgressDeadline,
+	}}
+	require.NoError(t, r.Status().Update(ctx, canary))
+
+	executer, deployment, result := reconcileDeployment(t, r, executer.Name)
+	assert.Zero(t, result)
+	assert.Equal(t, "worker:v1", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, []string{"Warning CanaryFailed"}, recordedEvents(recorder))
+	assert.Equal(t, appsv1alpha1.PhaseFailed, executer.Status.Phase)
+	assert.NotEqual(t, executer.Generation, executer.Status.ObservedGeneration)
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(canary), canary)))
+
+	condition := meta.FindStatusCondition(executer.Status.Conditions, appsv1alpha1.ConditionCanary)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, CanaryProgressDeadline, condition.Reason)
+
+	// the failed template isn't run again
+	_, deployment, result = reconcileDeployment(t, r, executer.Name)
+	assert.Zero(t, result)
+	assert.Equal(t, "worker:v1", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.Empty(t, recordedEvents(recorder))
+	assert.True(t, apierrors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(canary), canary)))
+
+	// until the executer is changed
+	executer.Spec.Image, executer.Generation = "worker:v3", 3
+	require.NoError(t, r.Update(ctx, executer))
+
+	_, _, result = reconcileDeployment(t, r, executer.Name)
+	assert.Equal(t, canaryRequeue, result.RequeueAfter)
+	assert.Equal(t, []string{"Normal CanaryStarted"}, recordedEvents(recorder))
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(canary), canary))
+	assert.Equal(t, "worker:v3", canary.Spec.Template.Spec.Containers[0].Image)
+}
@@ -0,0 +1,294 @@
+package apps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// ConfigChecksumAnnotation holds the checksum of the referenced ConfigMaps and Secrets on the pod template,
+// so their changes rollout the pods
+const ConfigChecksumAnnotation = "apps.mohammadne.me/config-checksum"
+
+// SetConfigChecksum annotates the pod template of the deployment with the checksum of the referenced configs
+func SetConfigChecksum(deployment *appsv1.Deployment, checksum string) {
+	setAnnotation(&deployment.Spec.Template.ObjectMeta, ConfigChecksumAnnotation, checksum)
+}
+
+// configReferenceIndex indexes the executers by their referenced ConfigMaps and Secrets
+const configReferenceIndex = "spec.configReferences"
+
+// ClaimName is the name of the claim created from the template of the volume
+func ClaimName(executer *appsv1alpha1.Executer, volume *appsv1alpha1.Volume) string {
+	return executer.Name + "-" + volume.Name
+}
+
+func volumes(executer *appsv1alpha1.Executer) []corev1.Volume {
+	if len(executer.Spec.Volumes) == 0 {
+		return nil
+	}
+
+	result := make([]corev1.Volume, 0, len(executer.Spec.Volumes))
+	for index := range executer.Spec.Volumes {
+		volume := &executer.Spec.Volumes[index]
+		source := corev1.VolumeSource{
+			ConfigMap:             volume.ConfigMap,
+			Secret:                volume.Secret,
+			EmptyDir:              volume.EmptyDir,
+			Projected:             volume.Projected,
+			HostPath:              volume.HostPath,
+			PersistentVolumeClaim: volume.PersistentVolumeClaim,
+		}
+
+		if volume.ClaimTemplate != nil {
+			source.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: ClaimName(executer, volume)}
+		}
+
+		result = append(result, corev1.Volume{Name: volume.Name, VolumeSource: source})
+	}
+
+	return result
+}
+
+// claimTemplates returns the claims of the volumes with a claim template
+func claimTemplates(executer *appsv1alpha1.Executer) []*corev1.PersistentVolumeClaim {
+	var claims []*corev1.PersistentVolumeClaim
+	for index := range executer.Spec.Volumes {
+		volume := &executer.Spec.Volumes[index]
+		if volume.ClaimTemplate == nil {
+			continue
+		}
+
+		claims = append(claims, &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ClaimName(executer, volume),
+				Namespace: executer.Namespace,
+				Labels:    Labels(executer),
+			},
+			Spec: *volume.ClaimTemplate.DeepCopy(),
+		})
+	}
+
+	return claims
+}
+
+// ReconcileClaims creates the missing claims of the executer, the existing ones are left untouched
+func (r *executer) ReconcileClaims(ctx context.Context, executer *appsv1alpha1.Executer, log *zap.Logger) error {
+	for _, desired := range claimTemplates(executer) {
+		if err := ctrl.SetControllerReference(executer, desired, r.scheme); err != nil {
+			log.Error("Failed to set reference", zap.Error(err))
+			return err
+		}
+
+		found := &corev1.PersistentVolumeClaim{}
+		err := r.Get(ctx, client.ObjectKeyFromObject(desired), found)
+		if err == nil {
+			continue
+		} else if !apierrors.IsNotFound(err) {
+			log.Error("Failed to get PersistentVolumeClaim", zap.String("claim", desired.Name), zap.Error(err))
+			return err
+		}
+
+		log.Info("Creating a new PersistentVolumeClaim", zap.String("claim", desired.Name))
+		if err := r.Create(ctx, desired); err != nil {
+			log.Error("Failed to create new PersistentVolumeClaim", zap.String("claim", desired.Name), zap.Error(err))
+			r.recorder.Eventf(executer, corev1.EventTypeWarning, ReasonClaimFailed, "Failed to create claim %s: %v", desired.Name, err)
+			return err
+		}
+
+		r.recorder.Eventf(executer, corev1.EventTypeNormal, ReasonClaimCreated, "Created claim %s", desired.Name)
+	}
+
+	return nil
+}
+
+// configReferences returns the ConfigMaps and Secrets used by the volumes and environment variables as kind/name
+func configReferences(executer *appsv1alpha1.Executer) []string {
+	references := map[string]bool{}
+	add := func(kind, name string) {
+		if name != "" {
+			references[kind+"/"+name] = true
+		}
+	}
+
+	for _, volume := range executer.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			add("ConfigMap", volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			add("Secret", volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					add("ConfigMap", source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					add("Secret", source.Secret.Name)
+				}
+			}
+		}
+	}
+
+	containers := append(append([]appsv1alpha1.Container{}, executer.Spec.Sidecars...), executer.Spec.InitContainers...)
+	for _, container := range containers {
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				add("ConfigMap", env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				add("Secret", env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+
+	result := make([]string, 0, len(references))
+	for reference := range references {
+		result = append(result, reference)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ConfigChecksum hashes the content of the referenced ConfigMaps and Secrets, empty if there is none.
+// The controller reads them through its configReader, as only the metadata of the Secrets is cached.
+func ConfigChecksum(ctx context.Context, reader client.Reader, executer *appsv1alpha1.Executer) (string, error) {
+	references := configReferences(executer)
+	if len(references) == 0 {
+		return "", nil
+	}
+
+	hash := sha256.New()
+	for _, reference := range references {
+		kind, name, _ := strings.Cut(reference, "/")
+		key := types.NamespacedName{Namespace: executer.Namespace, Name: name}
+
+		data := map[string][]byte{}
+		var err error
+		if kind == "ConfigMap" {
+			configMap := &corev1.ConfigMap{}
+			if err = reader.Get(ctx, key, configMap); err == nil {
+				for key, value := range configMap.Data {
+					data[key] = []byte(value)
+				}
+				for key, value := range configMap.BinaryData {
+					data[key] = value
+				}
+			}
+		} else {
+			secret := &corev1.Secret{}
+			if err = reader.Get(ctx, key, secret); err == nil {
+				data = secret.Data
+			}
+		}
+
+		// the missing ones are hashed as well, so their creation rollouts the pods
+		if apierrors.IsNotFound(err) {
+			fmt.Fprintf(hash, "%s missing\n", reference)
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(hash, "%s\n", reference)
+		for _, key := range keys {
+			fmt.Fprintf(hash, "%s=%x\n", key, data[key])
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// configReader reads the referenced ConfigMaps from the cache. Only the metadata of the Secrets is cached,
+// so their data is read from the api server once per resourceVersion and kept until they are changed.
+type configReader struct {
+	client.Reader
+	apiReader client.Reader
+
+	lock    sync.Mutex
+	secrets map[types.NamespacedName]*corev1.Secret
+}
+
+func newConfigReader(cache, apiReader client.Reader) *configReader {
+	return &configReader{Reader: cache, apiReader: apiReader, secrets: map[types.NamespacedName]*corev1.Secret{}}
+}
+
+func (r *configReader) Get(ctx context.Context, key client.ObjectKey, object client.Object, opts ...client.GetOption) error {
+	secret, ok := object.(*corev1.Secret)
+	if !ok {
+		return r.Reader.Get(ctx, key, object, opts...)
+	}
+
+	metadata := &metav1.PartialObjectMetadata{}
+	metadata.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	if err := r.Reader.Get(ctx, key, metadata); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.lock.Lock()
+			delete(r.secrets, key)
+			r.lock.Unlock()
+		}
+		return err
+	}
+
+	r.lock.Lock()
+	cached, found := r.secrets[key]
+	r.lock.Unlock()
+	if found && cached.ResourceVersion == metadata.ResourceVersion {
+		cached.DeepCopyInto(secret)
+		return nil
+	}
+
+	if err := r.apiReader.Get(ctx, key, secret, opts...); err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	r.secrets[key] = secret.DeepCopy()
+	r.lock.Unlock()
+	return nil
+}
+
+// referencingExecuters maps a ConfigMap or Secret to the executers referencing it
+func (r *executer) referencingExecuters(kind string) func(client.Object) []reconcile.Request {
+	return func(object client.Object) []reconcile.Request {
+		executers := &appsv1alpha1.ExecuterList{}
+		err := r.List(context.Background(), executers,
+			client.InNamespace(object.GetNamespace()), client.MatchingFields{configReferenceIndex: kind + "/" + object.GetName()})
+		if err != nil {
+			r.logger.Error("Failed to list executers referencing object",
+				zap.String("kind", kind), zap.String("namespace", object.GetNamespace()), zap.String("name", object.GetName()), zap.Error(err))
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(executers.Items))
+		for _, executer := range executers.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&executer)})
+		}
+		return requests
+	}
+}
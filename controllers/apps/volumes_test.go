@@ -0,0 +1,79 @@
+package apps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+// liveReads counts the objects read from the api server
+type liveReads struct {
+	client.Reader
+	reads []string
+}
+
+func (r *liveReads) Get(ctx context.Context, key client.ObjectKey, object client.Object, opts ...client.GetOption) error {
+	kind := "ConfigMap"
+	if _, ok := object.(*corev1.Secret); ok {
+		kind = "Secret"
+	}
+	r.reads = append(r.reads, kind+"/"+key.Name)
+	return r.Reader.Get(ctx, key, object, opts...)
+}
+
+func TestConfigReader(t *testing.T) {
+	ctx := context.Background()
+
+	executer := newTestExecuter()
+	executer.Spec.Volumes = append(executer.Spec.Volumes, appsv1alpha1.Volume{
+		Name:   "credentials",
+		Secret: &corev1.SecretVolumeSource{SecretName: "credentials"},
+	})
+	settings := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"}, Data: map[string]string{"a": "1"}}
+	credentials := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"}, Data: map[string][]byte{"token": []byte("v1")}}
+
+	r, _ := newTestReconciler(t, executer, settings, credentials)
+	live := &liveReads{Reader: r.Client}
+	reader := newConfigReader(r.Client, live)
+
+	checksum, err := ConfigChecksum(ctx, reader, executer)
+	require.NoError(t, err)
+	expected, err := ConfigChecksum(ctx, r.Client, executer)
+	require.NoError(t, err)
+	assert.Equal(t, expected, checksum)
+	assert.Equal(t, []string{"Secret/credentials"}, live.reads)
+
+	// the unchanged secret is served from the data read before
+	_, err = ConfigChecksum(ctx, reader, executer)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Secret/credentials"}, live.reads)
+
+	// the changed secret is read again, the configmaps are always read from the cache
+	credentials.Data["token"] = []byte("v2")
+	require.NoError(t, r.Update(ctx, credentials))
+	settings.Data["a"] = "2"
+	require.NoError(t, r.Update(ctx, settings))
+
+	changed, err := ConfigChecksum(ctx, reader, executer)
+	require.NoError(t, err)
+	assert.NotEqual(t, checksum, changed)
+	assert.Equal(t, []string{"Secret/credentials", "Secret/credentials"}, live.reads)
+
+	expected, err = ConfigChecksum(ctx, r.Client, executer)
+	require.NoError(t, err)
+	assert.Equal(t, expected, changed)
+
+	// the deleted secret is hashed as missing
+	require.NoError(t, r.Delete(ctx, credentials))
+	missing, err := ConfigChecksum(ctx, reader, executer)
+	require.NoError(t, err)
+	assert.NotEqual(t, changed, missing)
+	assert.Len(t, live.reads, 2)
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+const (
+	namespace = "sanjagh"
+	subsystem = "executer"
+)
+
+var phases = []appsv1alpha1.Phase{
+	appsv1alpha1.PhaseUnknown,
+	appsv1alpha1.PhaseIdle,
+	appsv1alpha1.PhaseCreating,
+	appsv1alpha1.PhaseCreated,
+	appsv1alpha1.PhaseUpdating,
+	appsv1alpha1.PhaseFailed,
+}
+
+var (
+	phase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "phase",
+		Help:      "The current phase of the Executer, 1 for the current phase and 0 for the others.",
+	}, []string{"namespace", "name", "phase"})
+
+	reconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "reconcile_total",
+		Help:      "Total number of reconciliations per Executer partitioned by their outcome.",
+	}, []string{"namespace", "name", "outcome"})
+
+	timeToCreated = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "time_to_created_seconds",
+		Help:      "Time taken from the creation of an Executer until it reaches the Created phase.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	driftCorrections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "drift_corrections_total",
+		Help:      "Total number of times the owned resources of an Executer were changed out of band and corrected.",
+	}, []string{"namespace", "name"})
+
+	rollbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "rollbacks_total",
+		Help:      "Total number of failed rollouts of an Executer reverted to its last good pod template, partitioned by their reason.",
+	}, []string{"namespace", "name", "reason"})
+
+	desiredReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "desired_replicas",
+		Help:      "Number of replicas requested by the Executer.",
+	}, []string{"namespace", "name"})
+
+	readyReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "ready_replicas",
+		Help:      "Number of ready replicas of the Executer's deployment.",
+	}, []string{"namespace", "name"})
+)
+
+// Register registers the Executer metrics on the controller-runtime registry
+func Register() error {
+	collectors := []prometheus.Collector{
+		phase, reconciles, timeToCreated, driftCorrections, rollbacks, desiredReplicas, readyReplicas,
+	}
+
+	for _, collector := range collectors {
+		if err := crmetrics.Registry.Register(collector); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeRequeue = "requeue"
+	OutcomeError   = "error"
+)
+
+// ObserveReconcile records the outcome of a single reconciliation of the Executer
+func ObserveReconcile(req ctrl.Request, result ctrl.Result, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	} else if !result.IsZero() {
+		outcome = OutcomeRequeue
+	}
+
+	reconciles.WithLabelValues(req.Namespace, req.Name, outcome).Inc()
+}
+
+// ObservePhase records the transition of the Executer from its previous phase into the given one
+func ObservePhase(executer *appsv1alpha1.Executer, previous appsv1alpha1.Phase) {
+	current := executer.Status.Phase
+	for _, p := range phases {
+		value := 0.0
+		if p == current {
+			value = 1
+		}
+		phase.WithLabelValues(executer.Namespace, executer.Name, string(p)).Set(value)
+	}
+
+	if previous == appsv1alpha1.PhaseCreating && current == appsv1alpha1.PhaseCreated {
+		timeToCreated.Observe(time.Since(executer.CreationTimestamp.Time).Seconds())
+	}
+}
+
+// ObserveReplicas records the desired and ready replicas of the Executer
+func ObserveReplicas(executer *appsv1alpha1.Executer, ready int32) {
+	desiredReplicas.WithLabelValues(executer.Namespace, executer.Name).Set(float64(executer.Spec.Replication))
+	readyReplicas.WithLabelValues(executer.Namespace, executer.Name).Set(float64(ready))
+}
+
+// ObserveDriftCorrection records a correction of an out of band change of the Executer's resources
+func ObserveDriftCorrection(executer *appsv1alpha1.Executer) {
+	driftCorrections.WithLabelValues(executer.Namespace, executer.Name).Inc()
+}
+
+// ObserveRollback records a revert of a failed rollout of the Executer
+func ObserveRollback(executer *appsv1alpha1.Executer, reason string) {
+	rollbacks.WithLabelValues(executer.Namespace, executer.Name, reason).Inc()
+}
+
+// Forget removes all the series of the given Executer
+func Forget(req ctrl.Request) {
+	labels := prometheus.Labels{"namespace": req.Namespace, "name": req.Name}
+	for _, vec := range []*prometheus.MetricVec{
+		phase.MetricVec, reconciles.MetricVec, driftCorrections.MetricVec, rollbacks.MetricVec,
+		desiredReplicas.MetricVec, readyReplicas.MetricVec,
+	} {
+		vec.DeletePartialMatch(labels)
+	}
+}
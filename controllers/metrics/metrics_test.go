@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	appsv1alpha1 "github.com/mohammadne/sanjagh/api/v1alpha1"
+)
+
+func newExecuter(name string, phase appsv1alpha1.Phase) *appsv1alpha1.Executer {
+	return &appsv1alpha1.Executer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Minute))},
+		Spec:       appsv1alpha1.ExecuterSpec{Replication: 3},
+		Status:     appsv1alpha1.ExecuterStatus{Phase: phase},
+	}
+}
+
+// observations returns the number of the observed times to created
+func observations(t *testing.T) uint64 {
+	metric := &dto.Metric{}
+	require.NoError(t, timeToCreated.Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestObservePhase(t *testing.T) {
+	executer := newExecuter("phase", appsv1alpha1.PhaseCreating)
+	ObservePhase(executer, appsv1alpha1.PhaseIdle)
+
+	for _, p := range phases {
+		expected := 0.0
+		if p == appsv1alpha1.PhaseCreating {
+			expected = 1
+		}
+		assert.Equal(t, expected, testutil.ToFloat64(phase.WithLabelValues("default", "phase", string(p))), p)
+	}
+
+	// the time to created is only observed on the transition from creating
+	before := observations(t)
+
+	executer.Status.Phase = appsv1alpha1.PhaseCreated
+	ObservePhase(executer, appsv1alpha1.PhaseCreating)
+	assert.Equal(t, 1.0, testutil.ToFloat64(phase.WithLabelValues("default", "phase", string(appsv1alpha1.PhaseCreated))))
+	assert.Equal(t, 0.0, testutil.ToFloat64(phase.WithLabelValues("default", "phase", string(appsv1alpha1.PhaseCreating))))
+	assert.Equal(t, before+1, observations(t))
+
+	executer.Status.Phase = appsv1alpha1.PhaseUpdating
+	ObservePhase(executer, appsv1alpha1.PhaseCreated)
+	assert.Equal(t, before+1, observations(t))
+}
+
+func TestObserveReconcile(t *testing.T) {
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "reconcile"}}
+
+	ObserveReconcile(req, ctrl.Result{}, nil)
+	ObserveReconcile(req, ctrl.Result{RequeueAfter: time.Second}, nil)
+	ObserveReconcile(req, ctrl.Result{Requeue: true}, nil)
+	ObserveReconcile(req, ctrl.Result{}, errors.New("conflict"))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(reconciles.WithLabelValues("default", "reconcile", OutcomeSuccess)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(reconciles.WithLabelValues("default", "reconcile", OutcomeRequeue)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(reconciles.WithLabelValues("default", "reconcile", OutcomeError)))
+}
+
+func TestForget(t *testing.T) {
+	forgotten, kept := newExecuter("forgotten", appsv1alpha1.PhaseCreated), newExecuter("kept", appsv1alpha1.PhaseCreated)
+	for _, executer := range []*appsv1alpha1.Executer{forgotten, kept} {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: executer.Namespace, Name: executer.Name}}
+		ObserveReconcile(req, ctrl.Result{}, nil)
+		ObservePhase(executer, appsv1alpha1.PhaseCreated)
+		ObserveReplicas(executer, 2)
+		ObserveDriftCorrection(executer)
+		ObserveRollback(executer, "ProgressDeadline")
+	}
+
+	vecs := []prometheus.Collector{phase, reconciles, driftCorrections, rollbacks, desiredReplicas, readyReplicas}
+	counts := make([]int, len(vecs))
+	for index, vec := range vecs {
+		counts[index] = testutil.CollectAndCount(vec)
+	}
+
+	Forget(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "forgotten"}})
+
+	// the series of the forgotten executer are deleted, the ones of the others are kept
+	expected := []int{len(phases), 1, 1, 1, 1, 1}
+	for index, vec := range vecs {
+		assert.Equal(t, counts[index]-expected[index], testutil.CollectAndCount(vec))
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(phase.WithLabelValues("default", "kept", string(appsv1alpha1.PhaseCreated))))
+	assert.Equal(t, 3.0, testutil.ToFloat64(desiredReplicas.WithLabelValues("default", "kept")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(rollbacks.WithLabelValues("default", "kept", "ProgressDeadline")))
+}
@@ -6,11 +6,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
-func Register(mgr manager.Manager, logger *zap.Logger) error {
-	executerController := apps.NewExecuter(mgr.GetClient(), mgr.GetScheme(), logger)
+// Register sets up the controllers on the manager, the returned function applies a new config to them
+func Register(mgr manager.Manager, cfg *apps.Config, logger *zap.Logger) (func(*apps.Config), error) {
+	recorder := mgr.GetEventRecorderFor("executer-controller")
+	executerController := apps.NewExecuter(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetScheme(), recorder, cfg, logger)
 	if err := executerController.SetupWithManager(mgr); err != nil {
 		logger.Fatal("Unable to create Executer controller", zap.Error(err))
 	}
 
-	return nil
+	return executerController.Reload, nil
 }
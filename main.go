@@ -2,6 +2,8 @@ package main
 
 import (
 	"log"
+	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 
@@ -13,12 +15,38 @@ func main() {
 	const description = "Sanjagh operator"
 	root := &cobra.Command{Short: description}
 
-	config := config.Load(true)
+	// installed as kubectl-executer, the binary works as a kubectl plugin with only the executer commands
+	cfg := &config.Config{}
+	executer := cmd.NewExecuter(cfg)
+	if filepath.Base(os.Args[0]) == cmd.PluginName {
+		root = executer
+	}
+
+	// the configuration gets loaded after parsing the flags
+	sources := config.Sources{}
+	root.PersistentFlags().StringSliceVar(&sources.Files, "config", nil, "The configuration files, merged in the given order")
+	root.PersistentFlags().StringArrayVar(&sources.Overrides, "set", nil, "Override a configuration key, e.g. --set logger.level=debug")
+	root.PersistentPreRunE = func(command *cobra.Command, _ []string) error {
+		loaded, err := config.Load(sources, command.Annotations[cmd.PrintConfig] == "true")
+		if err != nil {
+			return err
+		}
 
-	root.AddCommand(
-		cmd.NewManager(config),
-		cmd.NewWebhook(config),
-	)
+		*cfg = *loaded
+		return nil
+	}
+
+	if root != executer {
+		root.AddCommand(
+			cmd.NewManager(cfg),
+			cmd.NewWebhook(cfg),
+			cmd.NewAllInOne(cfg),
+			cmd.NewConfig(),
+			cmd.NewValidate(cfg),
+			cmd.NewRender(cfg),
+			executer,
+		)
+	}
 
 	if err := root.Execute(); err != nil {
 		log.Fatal(err.Error(), "failed to execute root command")
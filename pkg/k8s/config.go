@@ -12,3 +12,12 @@ func KubeConfig(path string) (*rest.Config, error) {
 	}
 	return ctrl.GetConfig()
 }
+
+// Namespace returns the namespace of the current context of the kubeconfig
+func Namespace(path string) (string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+
+	namespace, _, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).Namespace()
+	return namespace, err
+}
@@ -1,7 +1,78 @@
 package logger
 
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
 type Config struct {
-	Development bool   `koanf:"development"`
-	Encoding    string `koanf:"encoding"`
-	Level       string `koanf:"level"`
+	Development     bool              `koanf:"development"`
+	Encoding        string            `koanf:"encoding"`
+	Level           string            `koanf:"level"`
+	StacktraceLevel string            `koanf:"stacktrace_level"`
+	Components      map[string]string `koanf:"components"`
+	Outputs         []Output          `koanf:"outputs"`
+	Sampling        Sampling          `koanf:"sampling"`
+}
+
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	OutputFile   = "file"
+)
+
+// Output is a sink of the logs, the rotation settings are only used by the file outputs
+type Output struct {
+	Type       string `koanf:"type"`
+	Path       string `koanf:"path"`
+	MaxSize    int    `koanf:"max_size"`    // megabytes
+	MaxAge     int    `koanf:"max_age"`     // days
+	MaxBackups int    `koanf:"max_backups"` // files
+	Compress   bool   `koanf:"compress"`
+}
+
+// Sampling logs the first Initial entries with the same level and message
+// in each Tick and then every Thereafter-th entry of them.
+type Sampling struct {
+	Enabled    bool          `koanf:"enabled"`
+	Tick       time.Duration `koanf:"tick"`
+	Initial    int           `koanf:"initial"`
+	Thereafter int           `koanf:"thereafter"`
+}
+
+func (c *Config) Validate() error {
+	if c.Encoding != "console" && c.Encoding != "json" {
+		return fmt.Errorf("encoding should be either console or json: %s", c.Encoding)
+	}
+
+	levels := map[string]string{"level": c.Level, "stacktrace_level": c.StacktraceLevel}
+	for name, level := range c.Components {
+		levels["components."+name] = level
+	}
+
+	for key, level := range levels {
+		if _, err := zapcore.ParseLevel(level); err != nil {
+			return fmt.Errorf("invalid %s: %v", key, err)
+		}
+	}
+
+	for index, output := range c.Outputs {
+		switch output.Type {
+		case OutputStdout, OutputStderr:
+		case OutputFile:
+			if output.Path == "" {
+				return fmt.Errorf("path of the file output %d is empty", index)
+			}
+		default:
+			return fmt.Errorf("unsupported type of output %d: %s", index, output.Type)
+		}
+	}
+
+	if c.Sampling.Enabled && (c.Sampling.Tick <= 0 || c.Sampling.Initial <= 0 || c.Sampling.Thereafter <= 0) {
+		return fmt.Errorf("sampling tick, initial and thereafter should be positive")
+	}
+
+	return nil
 }
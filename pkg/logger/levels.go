@@ -0,0 +1,167 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levels keeps the level of the root logger and the levels of the named
+// components which are overridden, the rest of components follow the root.
+type levels struct {
+	mu         sync.RWMutex
+	root       zap.AtomicLevel
+	components map[string]zap.AtomicLevel
+}
+
+func newLevels(cfg *Config) *levels {
+	l := &levels{root: LoggerLevel(cfg), components: make(map[string]zap.AtomicLevel)}
+	for name, level := range cfg.Components {
+		l.components[name] = LoggerLevel(&Config{Level: level})
+	}
+	return l
+}
+
+func (l *levels) of(component string) zap.AtomicLevel {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if level, ok := l.components[component]; ok {
+		return level
+	}
+	return l.root
+}
+
+// reload applies the levels of the config, the components which are not in the config follow the root again
+func (l *levels) reload(cfg *Config) {
+	l.root.SetLevel(LoggerLevel(cfg).Level())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for name := range l.components {
+		if _, ok := cfg.Components[name]; !ok {
+			delete(l.components, name)
+		}
+	}
+
+	for name, level := range cfg.Components {
+		if atomic, ok := l.components[name]; ok {
+			atomic.SetLevel(LoggerLevel(&Config{Level: level}).Level())
+		} else {
+			l.components[name] = LoggerLevel(&Config{Level: level})
+		}
+	}
+}
+
+func (l *levels) set(component string, level zapcore.Level) {
+	if component == "" {
+		l.root.SetLevel(level)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if atomic, ok := l.components[component]; ok {
+		atomic.SetLevel(level)
+		return
+	}
+	l.components[component] = zap.NewAtomicLevelAt(level)
+}
+
+type levelsPayload struct {
+	Level      string            `json:"level"`
+	Component  string            `json:"component,omitempty"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+// ServeHTTP reports the levels on GET and changes the level of the root or a component on PUT
+func (l *levels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		payload := levelsPayload{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		var level zapcore.Level
+		if err := level.Set(payload.Level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		l.set(payload.Component, level)
+	default:
+		http.Error(w, "only GET and PUT are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload := levelsPayload{Level: l.root.String(), Components: make(map[string]string)}
+	l.mu.RLock()
+	for name, level := range l.components {
+		payload.Components[name] = level.String()
+	}
+	l.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payload)
+}
+
+// core filters the entries of the wrapped core based on the level of its component
+type core struct {
+	zapcore.Core
+	levels    *levels
+	component string
+}
+
+func (c *core) Enabled(level zapcore.Level) bool {
+	return c.levels.of(c.component).Enabled(level)
+}
+
+func (c *core) Level() zapcore.Level {
+	return c.levels.of(c.component).Level()
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{Core: c.Core.With(fields), levels: c.levels, component: c.component}
+}
+
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
+
+// Named returns a child logger for the given component, its level can be tuned
+// independently through the config or the level handler.
+func Named(lg *zap.Logger, component string) *zap.Logger {
+	return lg.Named(component).WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		if parent, ok := c.(*core); ok {
+			return &core{Core: parent.Core, levels: parent.levels, component: component}
+		}
+		return c
+	}))
+}
+
+// LevelHandler returns an http handler to get and set the levels of the given logger at runtime
+func LevelHandler(lg *zap.Logger) http.Handler {
+	if c, ok := lg.Core().(*core); ok {
+		return c.levels
+	}
+	return http.NotFoundHandler()
+}
+
+// Reload applies the levels of the given config to the logger and its components
+func Reload(lg *zap.Logger, cfg *Config) {
+	if c, ok := lg.Core().(*core); ok {
+		c.levels.reload(cfg)
+	}
+}
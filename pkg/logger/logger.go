@@ -6,6 +6,7 @@ import (
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 func NewNoop() *zap.Logger {
@@ -13,10 +14,12 @@ func NewNoop() *zap.Logger {
 }
 
 func NewZap(cfg *Config) *zap.Logger {
-	return zap.New(
-		zapcore.NewCore(Encoder(cfg), WriteSyncer(cfg), LoggerLevel(cfg)),
-		Options(cfg)...,
-	)
+	inner := zapcore.NewCore(Encoder(cfg), WriteSyncer(cfg), zapcore.DebugLevel)
+	if cfg.Sampling.Enabled {
+		inner = zapcore.NewSamplerWithOptions(inner, cfg.Sampling.Tick, cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+	}
+
+	return zap.New(&core{Core: inner, levels: newLevels(cfg)}, Options(cfg)...)
 }
 
 func Encoder(cfg *Config) zapcore.Encoder {
@@ -42,7 +45,32 @@ func Encoder(cfg *Config) zapcore.Encoder {
 }
 
 func WriteSyncer(cfg *Config) zapcore.WriteSyncer {
-	return zapcore.Lock(os.Stdout)
+	if len(cfg.Outputs) == 0 {
+		return zapcore.Lock(os.Stdout)
+	}
+
+	syncers := make([]zapcore.WriteSyncer, 0, len(cfg.Outputs))
+	for _, output := range cfg.Outputs {
+		switch output.Type {
+		case OutputStdout:
+			syncers = append(syncers, zapcore.Lock(os.Stdout))
+		case OutputStderr:
+			syncers = append(syncers, zapcore.Lock(os.Stderr))
+		case OutputFile:
+			syncers = append(syncers, zapcore.AddSync(&lumberjack.Logger{
+				Filename:   output.Path,
+				MaxSize:    output.MaxSize,
+				MaxAge:     output.MaxAge,
+				MaxBackups: output.MaxBackups,
+				Compress:   output.Compress,
+			}))
+		default:
+			log.Printf("using stdout for zap due to an unknown output type %s in user's config", output.Type)
+			syncers = append(syncers, zapcore.Lock(os.Stdout))
+		}
+	}
+
+	return zapcore.NewMultiWriteSyncer(syncers...)
 }
 
 func LoggerLevel(cfg *Config) zap.AtomicLevel {
@@ -56,9 +84,23 @@ func LoggerLevel(cfg *Config) zap.AtomicLevel {
 	return zap.NewAtomicLevelAt(level)
 }
 
+func StacktraceLevel(cfg *Config) zapcore.Level {
+	if cfg.StacktraceLevel == "" {
+		return zapcore.ErrorLevel
+	}
+
+	var level zapcore.Level
+	if err := level.Set(cfg.StacktraceLevel); err != nil {
+		log.Printf("using error stacktrace level for zap due to an error in user's config value %s", cfg.StacktraceLevel)
+		return zapcore.ErrorLevel
+	}
+
+	return level
+}
+
 func Options(cfg *Config) []zap.Option {
 	return []zap.Option{
-		zap.AddStacktrace(zap.ErrorLevel),
+		zap.AddStacktrace(StacktraceLevel(cfg)),
 		zap.AddCaller(),
 	}
 }
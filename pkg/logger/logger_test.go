@@ -1,7 +1,13 @@
 package logger
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -48,4 +54,79 @@ var _ = Describe("Logger Creation", func() {
 
 		Expect(NewZap(cfg).Level()).Should(Equal(zapcore.InfoLevel))
 	})
+
+	It("component levels are independent from the root", func() {
+		cfg := &Config{
+			Encoding:   "json",
+			Level:      "info",
+			Components: map[string]string{"validation": "debug"},
+		}
+
+		lg := NewZap(cfg)
+		Expect(Named(lg, "validation").Level()).Should(Equal(zapcore.DebugLevel))
+		Expect(Named(lg, "executer-controller").Level()).Should(Equal(zapcore.InfoLevel))
+	})
+
+	It("change levels at runtime through the handler", func() {
+		cfg := &Config{Encoding: "json", Level: "info"}
+
+		lg := NewZap(cfg)
+		component := Named(lg, "executer-controller")
+		handler := LevelHandler(lg)
+
+		body := strings.NewReader(`{"level":"error","component":"executer-controller"}`)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/log/level", body))
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		Expect(component.Level()).Should(Equal(zapcore.ErrorLevel))
+		Expect(lg.Level()).Should(Equal(zapcore.InfoLevel))
+
+		body = strings.NewReader(`{"level":"warn"}`)
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/log/level", body))
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		Expect(lg.Level()).Should(Equal(zapcore.WarnLevel))
+
+		body = strings.NewReader(`{"level":"invalid"}`)
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/log/level", body))
+		Expect(recorder.Code).Should(Equal(http.StatusBadRequest))
+	})
+
+	It("write logs into the file output with sampling", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "sanjagh.log")
+		cfg := &Config{
+			Encoding: "json",
+			Level:    "info",
+			Outputs:  []Output{{Type: OutputFile, Path: path, MaxSize: 1}},
+			Sampling: Sampling{Enabled: true, Tick: time.Minute, Initial: 2, Thereafter: 100},
+		}
+
+		lg := NewZap(cfg)
+		for i := 0; i < 10; i++ {
+			lg.Info("sampled message")
+		}
+		Expect(lg.Sync()).Should(Succeed())
+
+		content, err := os.ReadFile(path)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(strings.Count(string(content), "sampled message")).Should(Equal(2))
+	})
+
+	It("invalid stacktrace level", func() {
+		Expect(StacktraceLevel(&Config{StacktraceLevel: "invalid"})).Should(Equal(zapcore.ErrorLevel))
+		Expect(StacktraceLevel(&Config{StacktraceLevel: "warn"})).Should(Equal(zapcore.WarnLevel))
+	})
+
+	It("reload levels from a new config", func() {
+		lg := NewZap(&Config{Encoding: "json", Level: "info", Components: map[string]string{"validation": "debug"}})
+		validation := Named(lg, "validation")
+
+		Reload(lg, &Config{Level: "warn"})
+		Expect(lg.Level()).Should(Equal(zapcore.WarnLevel))
+		Expect(validation.Level()).Should(Equal(zapcore.WarnLevel))
+
+		Reload(lg, &Config{Level: "warn", Components: map[string]string{"validation": "error"}})
+		Expect(validation.Level()).Should(Equal(zapcore.ErrorLevel))
+	})
 })
@@ -0,0 +1,52 @@
+package rbac
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Rule grants the verbs on the resources of the api groups, "*" matches all of them
+type Rule struct {
+	APIGroups []string `koanf:"api_groups"`
+	Resources []string `koanf:"resources"`
+	Verbs     []string `koanf:"verbs"`
+}
+
+// Permission is a verb on a resource of an api group
+type Permission struct {
+	APIGroup string
+	Resource string
+	Verb     string
+}
+
+// EscalatingPermissions returns the permissions granted by the policy rule which aren't covered by the ceiling
+func EscalatingPermissions(ceiling []Rule, rule rbacv1.PolicyRule) []Permission {
+	var permissions []Permission
+	for _, group := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			for _, verb := range rule.Verbs {
+				if !coveredPermission(ceiling, group, resource, verb) {
+					permissions = append(permissions, Permission{APIGroup: group, Resource: resource, Verb: verb})
+				}
+			}
+		}
+	}
+	return permissions
+}
+
+func coveredPermission(ceiling []Rule, group, resource, verb string) bool {
+	for _, rule := range ceiling {
+		if matchRule(rule.APIGroups, group) && matchRule(rule.Resources, resource) && matchRule(rule.Verbs, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRule(values []string, value string) bool {
+	for _, item := range values {
+		if item == "*" || item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestEscalatingPermissions(t *testing.T) {
+	ceiling := []Rule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps", "pods"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{"batch"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+	}
+
+	tests := []struct {
+		name        string
+		rule        rbacv1.PolicyRule
+		permissions []Permission
+	}{
+		{
+			name: "covered",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+		},
+		{
+			name: "covered by wildcards",
+			rule: rbacv1.PolicyRule{APIGroups: []string{"batch"}, Resources: []string{"jobs", "cronjobs"}, Verbs: []string{"delete"}},
+		},
+		{
+			name: "escalating verb",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "delete"}},
+			permissions: []Permission{
+				{APIGroup: "", Resource: "pods", Verb: "delete"},
+			},
+		},
+		{
+			name: "escalating wildcard",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"*"}, Verbs: []string{"get"}},
+			permissions: []Permission{
+				{APIGroup: "", Resource: "*", Verb: "get"},
+			},
+		},
+		{
+			name: "escalating api group",
+			rule: rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			permissions: []Permission{
+				{APIGroup: "apps", Resource: "pods", Verb: "get"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.permissions, EscalatingPermissions(ceiling, test.rule))
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import "fmt"
+
+type Config struct {
+	Enabled     bool    `koanf:"enabled"`
+	ServiceName string  `koanf:"service_name"`
+	Exporter    string  `koanf:"exporter"`
+	SampleRatio float64 `koanf:"sample_ratio"`
+	OTLP        struct {
+		Endpoint string `koanf:"endpoint"`
+		Insecure bool   `koanf:"insecure"`
+	} `koanf:"otlp"`
+	File struct {
+		Path string `koanf:"path"`
+	} `koanf:"file"`
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample_ratio should be between 0 and 1: %v", c.SampleRatio)
+	}
+
+	switch c.Exporter {
+	case ExporterOTLP:
+		if c.OTLP.Endpoint == "" {
+			return fmt.Errorf("otlp endpoint is empty")
+		}
+	case ExporterStdout:
+	case ExporterFile:
+		if c.File.Path == "" {
+			return fmt.Errorf("file path is empty")
+		}
+	default:
+		return fmt.Errorf("unsupported exporter: %s", c.Exporter)
+	}
+
+	return nil
+}
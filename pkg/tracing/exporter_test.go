@@ -0,0 +1,24 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileExporterShutdown(t *testing.T) {
+	cfg := &Config{Exporter: ExporterFile}
+	cfg.File.Path = filepath.Join(t.TempDir(), "traces.json")
+
+	exporter, err := newExporter(cfg)
+	require.NoError(t, err)
+	require.IsType(t, &fileExporter{}, exporter)
+
+	require.NoError(t, exporter.Shutdown(context.Background()))
+	_, err = exporter.(*fileExporter).file.Write([]byte("span"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
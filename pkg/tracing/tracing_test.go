@@ -0,0 +1,41 @@
+package tracing_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+
+	"github.com/mohammadne/sanjagh/pkg/tracing"
+)
+
+func TestDisabledTracing(t *testing.T) {
+	shutdown, err := tracing.New(&tracing.Config{Enabled: false})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestUnsupportedExporter(t *testing.T) {
+	_, err := tracing.New(&tracing.Config{Enabled: true, Exporter: "invalid"})
+	assert.Error(t, err)
+}
+
+func TestFileExporter(t *testing.T) {
+	cfg := &tracing.Config{Enabled: true, Exporter: tracing.ExporterFile, SampleRatio: 1, ServiceName: "test"}
+	cfg.File.Path = filepath.Join(t.TempDir(), "traces.json")
+
+	shutdown, err := tracing.New(cfg)
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(cfg.File.Path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "test-span")
+}
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const (
+	DecisionAllowed = "allowed"
+	DecisionDenied  = "denied"
+	DecisionError   = "error"
+)
+
+// Record is the audit entry of a single AdmissionReview
+type Record struct {
+	Timestamp time.Time       `json:"timestamp"`
+	UID       string          `json:"uid"`
+	User      string          `json:"user"`
+	Groups    []string        `json:"groups,omitempty"`
+	Operation string          `json:"operation"`
+	Resource  string          `json:"resource"`
+	Namespace string          `json:"namespace,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Decision  string          `json:"decision"`
+	Reasons   []string        `json:"reasons,omitempty"`
+	Latency   float64         `json:"latency_ms"`
+	Object    json.RawMessage `json:"object,omitempty"`
+}
+
+type Auditor interface {
+	// Audit records the decision made for the review, err is the error occurred while handling it
+	Audit(review *admissionv1.AdmissionReview, err error, latency time.Duration)
+
+	// Close releases the underlying sink
+	Close() error
+}
+
+// New creates an auditor which writes the records into the configured sink
+func New(cfg *Config, lg *zap.Logger) (Auditor, error) {
+	if !cfg.Enabled {
+		return &noop{}, nil
+	}
+
+	lg = lg.Named("audit")
+	sink, err := newSink(cfg, lg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditor{config: cfg, sink: sink, logger: lg}, nil
+}
+
+type noop struct{}
+
+func (*noop) Audit(*admissionv1.AdmissionReview, error, time.Duration) {}
+
+func (*noop) Close() error { return nil }
+
+type auditor struct {
+	config *Config
+	sink   sink
+	logger *zap.Logger
+}
+
+func (a *auditor) Audit(review *admissionv1.AdmissionReview, err error, latency time.Duration) {
+	request := review.Request
+	record := &Record{
+		Timestamp: time.Now().UTC(),
+		UID:       string(request.UID),
+		User:      request.UserInfo.Username,
+		Groups:    request.UserInfo.Groups,
+		Operation: string(request.Operation),
+		Resource:  request.Resource.String(),
+		Namespace: request.Namespace,
+		Name:      request.Name,
+		Latency:   float64(latency.Microseconds()) / 1000,
+	}
+
+	switch {
+	case err != nil:
+		record.Decision = DecisionError
+		record.Reasons = []string{err.Error()}
+	case review.Response == nil:
+		record.Decision = DecisionError
+	case review.Response.Allowed:
+		record.Decision = DecisionAllowed
+	default:
+		record.Decision = DecisionDenied
+		if result := review.Response.Result; result != nil {
+			if result.Details != nil && len(result.Details.Causes) > 0 {
+				for _, cause := range result.Details.Causes {
+					record.Reasons = append(record.Reasons, cause.Message)
+				}
+			} else if result.Message != "" {
+				record.Reasons = []string{result.Message}
+			}
+		}
+	}
+
+	if a.config.Body.Include && len(request.Object.Raw) > 0 {
+		object, err := redact(request.Object.Raw, a.config.Body.Redact)
+		if err != nil {
+			a.logger.Warn("error redacting the request body, skipping it", zap.Error(err))
+		} else {
+			record.Object = object
+		}
+	}
+
+	if err := a.sink.write(record); err != nil {
+		a.logger.Error("error writing audit record", zap.String("uid", record.UID), zap.Error(err))
+	}
+}
+
+func (a *auditor) Close() error {
+	return a.sink.close()
+}
+
+const redacted = "[REDACTED]"
+
+// redact replaces the value of the given dot separated paths of the object
+func redact(raw []byte, paths []string) (json.RawMessage, error) {
+	if len(paths) == 0 {
+		return raw, nil
+	}
+
+	object := make(map[string]any)
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		keys := strings.Split(path, ".")
+		parent := object
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := parent[key].(map[string]any)
+			if !ok {
+				parent = nil
+				break
+			}
+			parent = child
+		}
+
+		last := keys[len(keys)-1]
+		if _, ok := parent[last]; ok {
+			parent[last] = redacted
+		}
+	}
+
+	return json.Marshal(object)
+}
@@ -0,0 +1,133 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/mohammadne/sanjagh/webhook/audit"
+)
+
+func newReview(allowed bool) *admissionv1.AdmissionReview {
+	return &admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "uid",
+			Operation: admissionv1.Create,
+			Resource:  metav1.GroupVersionResource{Group: "apps.mohammadne.me", Version: "v1alpha1", Resource: "executers"},
+			Namespace: "default",
+			Name:      "sample",
+			UserInfo:  authenticationv1.UserInfo{Username: "alice", Groups: []string{"developers"}},
+			Object:    runtime.RawExtension{Raw: []byte(`{"spec":{"image":"python","commands":["secret"]}}`)},
+		},
+		Response: &admissionv1.AdmissionResponse{
+			Allowed: allowed,
+			Result: &metav1.Status{
+				Details: &metav1.StatusDetails{Causes: []metav1.StatusCause{{Message: "too low"}}},
+			},
+		},
+	}
+}
+
+func readRecords(t *testing.T, path string) []audit.Record {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	records := []audit.Record{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		record := audit.Record{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestFileSink(t *testing.T) {
+	cfg := &audit.Config{Enabled: true}
+	cfg.Sink.Type = audit.SinkFile
+	cfg.Sink.Path = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Body.Include = true
+	cfg.Body.Redact = []string{"spec.commands", "spec.missing.field"}
+
+	auditor, err := audit.New(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	auditor.Audit(newReview(false), nil, time.Millisecond)
+	auditor.Audit(newReview(true), nil, time.Millisecond)
+	auditor.Audit(newReview(true), errors.New("boom"), time.Millisecond)
+
+	records := readRecords(t, cfg.Sink.Path)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, audit.DecisionDenied, records[0].Decision)
+	assert.Equal(t, []string{"too low"}, records[0].Reasons)
+	assert.Equal(t, "alice", records[0].User)
+	assert.Equal(t, []string{"developers"}, records[0].Groups)
+	assert.JSONEq(t, `{"spec":{"image":"python","commands":"[REDACTED]"}}`, string(records[0].Object))
+
+	assert.Equal(t, audit.DecisionAllowed, records[1].Decision)
+	assert.Equal(t, audit.DecisionError, records[2].Decision)
+	assert.Equal(t, []string{"boom"}, records[2].Reasons)
+}
+
+func TestUnsupportedSink(t *testing.T) {
+	cfg := &audit.Config{Enabled: true}
+	cfg.Sink.Type = "invalid"
+
+	_, err := audit.New(cfg, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestHTTPSinkQueue(t *testing.T) {
+	requested, release, received := make(chan struct{}, 10), make(chan struct{}), make(chan audit.Record, 10)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-release
+
+		record := audit.Record{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&record))
+		received <- record
+	}))
+	defer endpoint.Close()
+
+	cfg := &audit.Config{Enabled: true}
+	cfg.Sink.Type = audit.SinkHTTP
+	cfg.Sink.URL = endpoint.URL
+	cfg.Sink.Timeout = time.Minute
+	cfg.Sink.Queue = 1
+
+	auditor, err := audit.New(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	// the first record blocks the worker on the endpoint, the second fills the queue and the rest are dropped
+	dropped := testutil.ToFloat64(audit.DroppedRecords)
+	auditor.Audit(newReview(true), nil, time.Millisecond)
+	<-requested
+	auditor.Audit(newReview(false), nil, time.Millisecond)
+	auditor.Audit(newReview(false), nil, time.Millisecond)
+	assert.Equal(t, dropped+1, testutil.ToFloat64(audit.DroppedRecords))
+
+	close(release)
+	require.NoError(t, auditor.Close())
+	close(received)
+
+	decisions := []string{}
+	for record := range received {
+		decisions = append(decisions, record.Decision)
+	}
+	assert.Equal(t, []string{audit.DecisionAllowed, audit.DecisionDenied}, decisions)
+}
@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+)
+
+type Config struct {
+	Enabled bool `koanf:"enabled"`
+	Sink    struct {
+		Type    string        `koanf:"type"`
+		Path    string        `koanf:"path"`
+		URL     string        `koanf:"url" sensitive:"true"`
+		Timeout time.Duration `koanf:"timeout"`
+		Queue   int           `koanf:"queue"`
+	} `koanf:"sink"`
+	Body struct {
+		Include bool     `koanf:"include"`
+		Redact  []string `koanf:"redact"`
+	} `koanf:"body"`
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	switch c.Sink.Type {
+	case SinkStdout:
+	case SinkFile:
+		if c.Sink.Path == "" {
+			return fmt.Errorf("sink path is empty")
+		}
+	case SinkHTTP:
+		if c.Sink.URL == "" {
+			return fmt.Errorf("sink url is empty")
+		} else if c.Sink.Queue <= 0 {
+			return fmt.Errorf("sink queue must be positive: %d", c.Sink.Queue)
+		}
+	default:
+		return fmt.Errorf("unsupported sink: %s", c.Sink.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// DroppedRecords counts the audit records dropped as the queue of the http sink was full,
+// it should be registered on the registry of the running command.
+var DroppedRecords = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "sanjagh",
+	Subsystem: "audit",
+	Name:      "dropped_records_total",
+	Help:      "Total number of audit records dropped as the queue of the sink was full.",
+})
+
+const (
+	SinkStdout = "stdout"
+	SinkFile   = "file"
+	SinkHTTP   = "http"
+)
+
+type sink interface {
+	write(*Record) error
+	close() error
+}
+
+func newSink(cfg *Config, lg *zap.Logger) (sink, error) {
+	switch cfg.Sink.Type {
+	case SinkStdout:
+		return &writerSink{writer: os.Stdout}, nil
+	case SinkFile:
+		file, err := os.OpenFile(cfg.Sink.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("error opening audit file: %v", err)
+		}
+		return &writerSink{writer: file}, nil
+	case SinkHTTP:
+		return newHTTPSink(cfg, lg), nil
+	default:
+		return nil, fmt.Errorf("unsupported audit sink: %s", cfg.Sink.Type)
+	}
+}
+
+// writerSink writes each record as a json line into the writer
+type writerSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func (s *writerSink) write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.writer.Write(append(line, '\n'))
+	return err
+}
+
+func (s *writerSink) close() error {
+	if closer, ok := s.writer.(io.Closer); ok && s.writer != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
+
+// httpSink posts each record as a json document to the url in background, so the reviews don't wait on the endpoint,
+// the records are dropped while its bounded queue is full
+type httpSink struct {
+	url    string
+	client *http.Client
+	logger *zap.Logger
+
+	queue chan *Record
+	done  chan struct{}
+}
+
+func newHTTPSink(cfg *Config, lg *zap.Logger) *httpSink {
+	s := &httpSink{
+		url:    cfg.Sink.URL,
+		client: &http.Client{Timeout: cfg.Sink.Timeout},
+		logger: lg,
+		queue:  make(chan *Record, cfg.Sink.Queue),
+		done:   make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// run posts the queued records until the queue is closed
+func (s *httpSink) run() {
+	defer close(s.done)
+
+	for record := range s.queue {
+		if err := s.post(record); err != nil {
+			s.logger.Error("error writing audit record", zap.String("uid", record.UID), zap.Error(err))
+		}
+	}
+}
+
+func (s *httpSink) write(record *Record) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		DroppedRecords.Inc()
+		return fmt.Errorf("audit queue is full, the record is dropped")
+	}
+}
+
+func (s *httpSink) post(record *Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	response, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code from audit endpoint: %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// close posts the queued records and waits for them, no record must be written afterwards
+func (s *httpSink) close() error {
+	close(s.queue)
+	<-s.done
+
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/mohammadne/sanjagh/pkg/logger"
+)
+
+// Register serves the webhook endpoints on the webhook server of controller-runtime instead of the master app
+func (server *Server) Register(webhookServer *webhook.Server) {
+	webhookServer.Register("/validation", &webhook.Admission{Handler: &admissionHandler{server: server, action: server.validation.Validate}})
+	webhookServer.Register("/mutation", http.HandlerFunc(notImplemented))
+	webhookServer.Register("/conversion", http.HandlerFunc(notImplemented))
+}
+
+// ManagementHandlers are the endpoints of the management app other than metrics, for serving them on other servers
+func (server *Server) ManagementHandlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		"/healthz/liveness":  http.HandlerFunc(ok),
+		"/healthz/readiness": http.HandlerFunc(ok),
+		"/log/level":         logger.LevelHandler(server.logger),
+	}
+}
+
+// admissionHandler adapts an action of the server to the admission.Handler of controller-runtime
+type admissionHandler struct {
+	server *Server
+	action func(context.Context, *admissionv1.AdmissionReview) error
+}
+
+func (handler *admissionHandler) Handle(ctx context.Context, request admission.Request) admission.Response {
+	review := &admissionv1.AdmissionReview{Request: &request.AdmissionRequest}
+	return handler.server.respond(ctx, review, handler.action)
+}
+
+// respond runs the action on the review and builds its response the same way for both backends,
+// the failed reviews are denied with the status of the error rather than failing the webhook call
+func (server *Server) respond(ctx context.Context, review *admissionv1.AdmissionReview, action func(context.Context, *admissionv1.AdmissionReview) error) admission.Response {
+	if err := server.handle(ctx, review, action); err != nil {
+		return admission.Errored(http.StatusBadRequest, errors.New("error validating resource"))
+	} else if review.Response == nil {
+		return admission.Errored(http.StatusInternalServerError, errors.New("no response for admission review"))
+	}
+
+	return admission.Response{AdmissionResponse: *review.Response}
+}
+
+func ok(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func notImplemented(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNotImplemented)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/mohammadne/sanjagh/webhook/audit"
+	"github.com/mohammadne/sanjagh/webhook/validation/config"
+)
+
+// stubValidation answers the reviews with the stored decision, or fails them with the stored error
+type stubValidation struct {
+	allowed bool
+	err     error
+}
+
+func (v *stubValidation) Validate(_ context.Context, review *admissionv1.AdmissionReview) error {
+	if v.err != nil {
+		return v.err
+	}
+
+	review.Response = &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: v.allowed}
+	if !v.allowed {
+		review.Response.Result = &metav1.Status{Message: "denied by the stub", Code: http.StatusForbidden}
+	}
+	return nil
+}
+
+func (v *stubValidation) Reload(*config.Config) {}
+
+// serveFiber sends the review to the master app and returns the response review
+func serveFiber(t *testing.T, server *Server, body []byte) *admissionv1.AdmissionReview {
+	request := httptest.NewRequest(http.MethodPost, "/validation", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := server.masterApp.Test(request)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	review := &admissionv1.AdmissionReview{}
+	require.NoError(t, json.NewDecoder(response.Body).Decode(review))
+	return review
+}
+
+// serveControllerRuntime sends the review to the webhook of controller-runtime and returns the response review
+func serveControllerRuntime(t *testing.T, server *Server, body []byte) *admissionv1.AdmissionReview {
+	request := httptest.NewRequest(http.MethodPost, "/validation", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	admission := &webhook.Admission{Handler: &admissionHandler{server: server, action: server.validation.Validate}}
+	require.NoError(t, admission.InjectLogger(logr.Discard()))
+	admission.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	review := &admissionv1.AdmissionReview{}
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(review))
+	return review
+}
+
+func TestBackendsParity(t *testing.T) {
+	tests := []struct {
+		name       string
+		validation *stubValidation
+		allowed    bool
+		code       int32
+	}{
+		{name: "allowed", validation: &stubValidation{allowed: true}, allowed: true, code: http.StatusOK},
+		{name: "denied", validation: &stubValidation{}, allowed: false, code: http.StatusForbidden},
+		{name: "failed", validation: &stubValidation{err: errors.New("no executer")}, allowed: false, code: http.StatusBadRequest},
+	}
+
+	body, err := json.Marshal(&admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "review-uid",
+			Operation: admissionv1.Create,
+			Resource:  metav1.GroupVersionResource{Group: "apps.mohammadne.me", Version: "v1alpha1", Resource: "executers"},
+			Namespace: "default",
+			Name:      "worker",
+		},
+	})
+	require.NoError(t, err)
+
+	// the server is shared as its metrics are registered globally
+	auditor, err := audit.New(&audit.Config{}, zap.NewNop())
+	require.NoError(t, err)
+	server := New(&Config{}, zap.NewNop(), nil, auditor)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server.validation = test.validation
+
+			fiberReview, controllerRuntimeReview := serveFiber(t, server, body), serveControllerRuntime(t, server, body)
+			assert.Equal(t, controllerRuntimeReview, fiberReview)
+
+			require.NotNil(t, fiberReview.Response)
+			assert.Equal(t, "review-uid", string(fiberReview.Response.UID))
+			assert.Equal(t, test.allowed, fiberReview.Response.Allowed)
+			require.NotNil(t, fiberReview.Response.Result)
+			assert.Equal(t, test.code, fiberReview.Response.Result.Code)
+		})
+	}
+}
@@ -1,11 +1,22 @@
 package server
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	BackendFiber             = "fiber"
+	BackendControllerRuntime = "controller-runtime"
+)
 
 type Config struct {
-	TLS struct {
-		Certificate string `koanf:"certificate"`
-		PrivateKey  string `koanf:"private_key"`
+	// Backend serves the webhook either by the fiber apps or the webhook server of controller-runtime
+	Backend string `koanf:"backend"`
+	TLS     struct {
+		Certificate string `koanf:"certificate" sensitive:"true"`
+		PrivateKey  string `koanf:"private_key" sensitive:"true"`
 	} `koanf:"tls"`
 }
 
@@ -13,5 +24,36 @@ func (c *Config) Validate() error {
 	if c.TLS.Certificate == "" || c.TLS.PrivateKey == "" {
 		return fmt.Errorf("TLS Certificate or PrivateKey is empty")
 	}
+
+	switch c.Backend {
+	case BackendFiber:
+	case BackendControllerRuntime:
+		// controller-runtime loads both of them from a single directory
+		if filepath.Dir(c.TLS.Certificate) != filepath.Dir(c.TLS.PrivateKey) {
+			return fmt.Errorf("TLS Certificate and PrivateKey are not in the same directory for backend %q", c.Backend)
+		}
+	default:
+		return fmt.Errorf("invalid backend %q, must be one of %q or %q", c.Backend, BackendFiber, BackendControllerRuntime)
+	}
+
+	return nil
+}
+
+// ValidateFiles checks the TLS files exist, done by the processes serving the webhook
+// as the other ones load the same configuration without them being mounted
+func (c *Config) ValidateFiles() error {
+	files := []struct{ name, path string }{
+		{"Certificate", c.TLS.Certificate},
+		{"PrivateKey", c.TLS.PrivateKey},
+	}
+
+	for _, file := range files {
+		if info, err := os.Stat(file.path); err != nil {
+			return fmt.Errorf("TLS %s is not readable: %v", file.name, err)
+		} else if info.IsDir() {
+			return fmt.Errorf("TLS %s %q is a directory", file.name, file.path)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidateFiles(t *testing.T) {
+	dir := t.TempDir()
+	certificate, privateKey := filepath.Join(dir, "crt.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certificate, []byte("certificate"), 0o600))
+	require.NoError(t, os.WriteFile(privateKey, []byte("key"), 0o600))
+
+	tests := []struct {
+		name        string
+		certificate string
+		privateKey  string
+		err         string
+	}{
+		{name: "existing", certificate: certificate, privateKey: privateKey},
+		{
+			name:        "missing private key",
+			certificate: certificate,
+			privateKey:  filepath.Join(dir, "missing.pem"),
+			err:         "TLS PrivateKey is not readable: stat " + filepath.Join(dir, "missing.pem") + ": no such file or directory",
+		},
+		{
+			name:        "directory certificate",
+			certificate: dir,
+			privateKey:  privateKey,
+			err:         "TLS Certificate \"" + dir + "\" is a directory",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &Config{Backend: BackendFiber}
+			cfg.TLS.Certificate, cfg.TLS.PrivateKey = test.certificate, test.privateKey
+
+			// the files aren't checked by the validation of the loaded configuration
+			require.NoError(t, cfg.Validate())
+
+			if test.err == "" {
+				assert.NoError(t, cfg.ValidateFiles())
+			} else {
+				assert.EqualError(t, cfg.ValidateFiles(), test.err)
+			}
+		})
+	}
+}
@@ -2,14 +2,36 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+var tracer = otel.Tracer("github.com/mohammadne/sanjagh/webhook/server")
+
+// traceMiddleware continues the trace propagated by the caller, e.g. the api-server, in the user context of the request
+func traceMiddleware(c *fiber.Ctx) error {
+	header := http.Header{}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		header.Add(string(key), string(value))
+	})
+
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), propagation.HeaderCarrier(header))
+	c.SetUserContext(ctx)
+	return c.Next()
+}
+
 func (server *Server) livenessHandler(c *fiber.Ctx) error {
 	return c.SendStatus(http.StatusOK)
 }
@@ -18,17 +40,57 @@ func (server *Server) readinessHandler(c *fiber.Ctx) error {
 	return c.SendStatus(http.StatusOK)
 }
 
+// webhookHandler answers the reviews like the webhook server of controller-runtime,
+// so the backends behave the same regardless of the failure policy of the webhook
 func (server *Server) webhookHandler(c *fiber.Ctx, action func(context.Context, *admissionv1.AdmissionReview) error) error {
 	request := admissionv1.AdmissionReview{}
 	if err := c.BodyParser(&request); err != nil {
 		server.logger.Error("Error parsing request body", zap.Any("request", request), zap.Error(err))
-		return c.Status(http.StatusBadRequest).SendString("Error parsing request body")
+		return server.writeReview(c, request.TypeMeta, admission.Errored(http.StatusBadRequest, err))
 	} else if request.Request == nil {
 		server.logger.Error("admission review can't be used: Request field is nil", zap.Any("request", request), zap.Error(err))
-		return c.Status(http.StatusBadRequest).SendString("AdmissionReview can't be used: Request field is nil")
+		return server.writeReview(c, request.TypeMeta, admission.Errored(http.StatusBadRequest, errors.New("AdmissionReview can't be used: Request field is nil")))
+	}
+
+	response := server.respond(c.UserContext(), &request, action)
+	if err := response.Complete(admission.Request{AdmissionRequest: *request.Request}); err != nil {
+		server.logger.Error("Error completing admission response", zap.Error(err))
+		response = admission.Errored(http.StatusInternalServerError, err)
 	}
 
-	if err := action(c.Context(), &request); err != nil {
+	return server.writeReview(c, request.TypeMeta, response)
+}
+
+// writeReview writes the response in an AdmissionReview of the requested version, v1 by default
+func (server *Server) writeReview(c *fiber.Ctx, typeMeta metav1.TypeMeta, response admission.Response) error {
+	if typeMeta.APIVersion == "" || typeMeta.Kind == "" {
+		typeMeta = metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"}
+	}
+
+	return c.Status(http.StatusOK).JSON(&admissionv1.AdmissionReview{TypeMeta: typeMeta, Response: &response.AdmissionResponse})
+}
+
+// handle runs the action on the admission review with tracing, auditing and logging, regardless of the backend
+func (server *Server) handle(ctx context.Context, request *admissionv1.AdmissionReview, action func(context.Context, *admissionv1.AdmissionReview) error) error {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "webhook.admission")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("admission.uid", string(request.Request.UID)),
+		attribute.String("admission.resource", request.Request.Resource.String()),
+		attribute.String("admission.operation", string(request.Request.Operation)),
+		attribute.String("admission.namespace", request.Request.Namespace),
+		attribute.String("admission.name", request.Request.Name),
+	)
+
+	err := action(ctx, request)
+	server.audit(request, err, time.Since(start))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error validating resource")
+
 		fields := []zapcore.Field{
 			zap.String("resource", request.Request.Resource.String()),
 			zap.String("namespace", request.Request.Namespace),
@@ -40,11 +102,23 @@ func (server *Server) webhookHandler(c *fiber.Ctx, action func(context.Context,
 		}
 
 		server.logger.Error("error validating resource", fields...)
-		return c.Status(http.StatusBadRequest).SendString("error validating resource")
+		return err
+	}
+
+	if request.Response != nil {
+		span.SetAttributes(attribute.Bool("admission.allowed", request.Response.Allowed))
 	}
 
 	server.logger.Info("handled admission review")
-	return c.Status(http.StatusOK).JSON(&request)
+	return nil
+}
+
+// audit records the review on the current auditor, which can't be closed meanwhile
+func (server *Server) audit(request *admissionv1.AdmissionReview, err error, latency time.Duration) {
+	server.auditLock.RLock()
+	defer server.auditLock.RUnlock()
+
+	server.auditor.Audit(request, err, latency)
 }
 
 func (server *Server) validationHandler(c *fiber.Ctx) error {
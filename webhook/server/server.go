@@ -1,13 +1,20 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/ansrivas/fiberprometheus/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"go.uber.org/zap"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
+	"github.com/mohammadne/sanjagh/pkg/logger"
+	"github.com/mohammadne/sanjagh/webhook/audit"
 	"github.com/mohammadne/sanjagh/webhook/validation"
 )
 
@@ -15,16 +22,19 @@ type Server struct {
 	config     *Config
 	logger     *zap.Logger
 	validation validation.Validation
+	auditor    audit.Auditor
+	auditLock  sync.RWMutex // held for reading while auditing, so the replaced auditor is closed once drained
 
 	managementApp *fiber.App // the metrics and probe App
 	masterApp     *fiber.App // the webhook App
 }
 
-func New(cfg *Config, lg *zap.Logger, validation validation.Validation) *Server {
+func New(cfg *Config, lg *zap.Logger, validation validation.Validation, auditor audit.Auditor) *Server {
 	server := &Server{
 		config:     cfg,
 		logger:     lg,
 		validation: validation,
+		auditor:    auditor,
 	}
 
 	fiberConfig := fiber.Config{
@@ -42,12 +52,15 @@ func New(cfg *Config, lg *zap.Logger, validation validation.Validation) *Server
 	healthz.Get("/liveness", server.livenessHandler)
 	healthz.Get("/readiness", server.readinessHandler)
 
+	server.managementApp.All("/log/level", adaptor.HTTPHandler(logger.LevelHandler(lg)))
+
 	prometheus := fiberprometheus.New("sanjagh")
 	prometheus.RegisterAt(server.managementApp, "/metrics")
 	server.managementApp.Use(prometheus.Middleware)
 
 	// Master Endpoints
 
+	server.masterApp.Use(traceMiddleware)
 	server.masterApp.Post("/validation", server.validationHandler)
 	server.masterApp.Post("/mutation", server.mutationHandler)
 	server.masterApp.Post("/conversion", server.conversionHandler)
@@ -55,18 +68,58 @@ func New(cfg *Config, lg *zap.Logger, validation validation.Validation) *Server
 	return server
 }
 
+// SetAuditor replaces the auditor of the server and closes the previous one after its in-flight records are written
+func (server *Server) SetAuditor(auditor audit.Auditor) {
+	server.auditLock.Lock()
+	previous := server.auditor
+	server.auditor = auditor
+	server.auditLock.Unlock()
+
+	if err := previous.Close(); err != nil {
+		server.logger.Warn("Error closing the previous auditor", zap.Error(err))
+	}
+}
+
+// Serve starts the apps in background, the process exits if any of them fails
 func (server *Server) Serve(managementPort, webhookPort int) {
+	go func() {
+		err := server.Start(context.Background(), managementPort, webhookPort)
+		server.logger.Fatal("Error resolving servers", zap.Error(err))
+	}()
+}
+
+// shutdownTimeout is the grace period of the in-flight requests
+const shutdownTimeout = 10 * time.Second
+
+// Start serves the apps until one of them fails or the context is done, then shuts both of them down
+func (server *Server) Start(ctx context.Context, managementPort, webhookPort int) error {
+	errs := make(chan error, 2)
+
 	go func() {
 		addr := fmt.Sprintf(":%d", managementPort)
 		server.logger.Info("Management server listens on", zap.String("address", addr))
-		err := server.managementApp.Listen(addr)
-		server.logger.Fatal("Error resolving management server", zap.Error(err))
+		if err := server.managementApp.Listen(addr); err != nil {
+			errs <- fmt.Errorf("error resolving management server: %v", err)
+		}
 	}()
 
 	go func() {
 		addr := fmt.Sprintf(":%d", webhookPort)
 		server.logger.Info("Master (webhook) server listens on", zap.String("address", addr))
-		err := server.masterApp.ListenTLS(addr, server.config.TLS.Certificate, server.config.TLS.PrivateKey)
-		server.logger.Fatal("Error resolving webhook server", zap.Error(err))
+		if err := server.masterApp.ListenTLS(addr, server.config.TLS.Certificate, server.config.TLS.PrivateKey); err != nil {
+			errs <- fmt.Errorf("error resolving webhook server: %v", err)
+		}
 	}()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+
+	server.logger.Info("Shutting down the servers")
+	return utilerrors.NewAggregate([]error{err,
+		server.masterApp.ShutdownWithTimeout(shutdownTimeout),
+		server.managementApp.ShutdownWithTimeout(shutdownTimeout),
+	})
 }
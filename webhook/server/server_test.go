@@ -0,0 +1,87 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// blockingAuditor blocks the audits until released and records whether they ran after it was closed
+type blockingAuditor struct {
+	started, release chan struct{}
+	closed           atomic.Bool
+	auditedClosed    atomic.Bool
+}
+
+func (a *blockingAuditor) Audit(*admissionv1.AdmissionReview, error, time.Duration) {
+	close(a.started)
+	<-a.release
+	a.auditedClosed.Store(a.closed.Load())
+}
+
+func (a *blockingAuditor) Close() error {
+	a.closed.Store(true)
+	return nil
+}
+
+func TestSetAuditorDrains(t *testing.T) {
+	previous := &blockingAuditor{started: make(chan struct{}), release: make(chan struct{})}
+	server := &Server{auditor: previous}
+
+	audited := make(chan struct{})
+	go func() {
+		server.audit(&admissionv1.AdmissionReview{}, nil, time.Millisecond)
+		close(audited)
+	}()
+	<-previous.started
+
+	replaced := make(chan struct{})
+	go func() {
+		server.SetAuditor(&blockingAuditor{})
+		close(replaced)
+	}()
+
+	select {
+	case <-replaced:
+		t.Fatal("the auditor is replaced while auditing")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(previous.release)
+	<-audited
+	<-replaced
+	assert.False(t, previous.auditedClosed.Load())
+	assert.True(t, previous.closed.Load())
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(previous)
+
+	app := fiber.New()
+	app.Use(traceMiddleware)
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(trace.SpanContextFromContext(c.UserContext()).TraceID().String())
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	response, err := app.Test(request)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", string(body))
+}
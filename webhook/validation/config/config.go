@@ -1,8 +1,51 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mohammadne/sanjagh/pkg/rbac"
+)
+
 type Config struct {
 	Replication struct {
 		Maximum int32 `koanf:"maximum"`
 		Minimum int32 `koanf:"minimum"`
 	} `koanf:"replication"`
+
+	// Images is the policy applied to the images of all the containers
+	Images struct {
+		// AllowedRegistries are the prefixes of the allowed images, e.g. docker.io/library, any image is allowed if empty
+		AllowedRegistries []string `koanf:"allowed_registries"`
+		// DenyLatest rejects the images without a tag or digest and the ones with the latest tag
+		DenyLatest bool `koanf:"deny_latest"`
+	} `koanf:"images"`
+
+	Security struct {
+		// PrivilegedNamespaces are allowed to run privileged containers and mount host paths
+		PrivilegedNamespaces []string `koanf:"privileged_namespaces"`
+	} `koanf:"security"`
+
+	// RBAC is set from the one of the controller (controller.rbac), so the executers beyond its ceiling are rejected early
+	RBAC struct {
+		Ceiling []rbac.Rule
+	} `koanf:"-"`
+}
+
+func (c *Config) Validate() error {
+	if c.Replication.Minimum < 0 {
+		return fmt.Errorf("replication minimum is negative: %d", c.Replication.Minimum)
+	}
+
+	if c.Replication.Minimum > c.Replication.Maximum {
+		return fmt.Errorf("replication minimum (%d) is greater than its maximum (%d)", c.Replication.Minimum, c.Replication.Maximum)
+	}
+
+	for _, registry := range c.Images.AllowedRegistries {
+		if strings.TrimSuffix(registry, "/") == "" {
+			return fmt.Errorf("images allowed registry is empty")
+		}
+	}
+
+	return nil
 }
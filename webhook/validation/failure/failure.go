@@ -3,6 +3,8 @@ package failure
 import (
 	"fmt"
 	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type Failure []string
@@ -23,3 +25,11 @@ func (r Failure) Reason() string {
 	reason = strings.TrimSuffix(reason, ",")
 	return reason
 }
+
+func (r Failure) Causes() []metav1.StatusCause {
+	causes := make([]metav1.StatusCause, 0, len(r))
+	for _, response := range r {
+		causes = append(causes, metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid, Message: response})
+	}
+	return causes
+}
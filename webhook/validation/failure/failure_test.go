@@ -15,6 +15,8 @@ func TestInvalidResponse(t *testing.T) {
 	assert.Equal(t, "invalid parameter1,invalid parameter2", f.Reason())
 	assert.Contains(t, f, "invalid parameter1")
 	assert.Contains(t, f, "invalid parameter2")
+	assert.Len(t, f.Causes(), 2)
+	assert.Equal(t, "invalid parameter2", f.Causes()[1].Message)
 }
 
 func TestValidResponse(t *testing.T) {
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 
+	"go.uber.org/zap"
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	crclient "sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/mohammadne/sanjagh/pkg/logger"
 	"github.com/mohammadne/sanjagh/webhook/validation/config"
 	"github.com/mohammadne/sanjagh/webhook/validation/failure"
 	"github.com/mohammadne/sanjagh/webhook/validation/validators"
@@ -16,13 +18,16 @@ import (
 
 type Validation interface {
 	Validate(context.Context, *admissionv1.AdmissionReview) error
+	Reload(*config.Config)
 }
 
-func NewValidation(cfg *config.Config, client crclient.Reader) Validation {
-	v := &validation{client: client}
+func NewValidation(cfg *config.Config, client crclient.Reader, lg *zap.Logger) Validation {
+	v := &validation{client: client, logger: logger.Named(lg, "validation")}
 
 	// add more validators here
-	v.executersValidator = validators.NewExecuter(cfg, client).Validate
+	executers := validators.NewExecuter(cfg, client)
+	v.executersValidator = executers.Validate
+	v.reloaders = append(v.reloaders, executers.Reload)
 
 	return v
 }
@@ -31,17 +36,26 @@ type Validator func(context.Context, *admissionv1.AdmissionReview) (*failure.Fai
 
 type validation struct {
 	client client.Reader
+	logger *zap.Logger
 
 	executersValidator Validator
+
+	reloaders []func(*config.Config)
+}
+
+func (v *validation) Reload(cfg *config.Config) {
+	for _, reload := range v.reloaders {
+		reload(cfg)
+	}
 }
 
 func (v *validation) Validate(ctx context.Context, ar *admissionv1.AdmissionReview) error {
-	var failure *failure.Failure
+	var reasons *failure.Failure
 	var err error
 
 	switch ar.Request.Resource.Resource {
 	case "executers":
-		failure, err = v.executersValidator(ctx, ar)
+		reasons, err = v.executersValidator(ctx, ar)
 	default:
 		err = fmt.Errorf("unsupported resource: %s", ar.Request.Resource.Resource)
 	}
@@ -50,11 +64,28 @@ func (v *validation) Validate(ctx context.Context, ar *admissionv1.AdmissionRevi
 		return err
 	}
 
+	// the validators skip the objects they don't check, like the ones being deleted
+	if reasons == nil {
+		reasons = &failure.Failure{}
+	}
+
+	v.logger.Debug("validated admission review",
+		zap.String("uid", string(ar.Request.UID)),
+		zap.String("resource", ar.Request.Resource.Resource),
+		zap.Bool("allowed", reasons.IsAllowed()),
+		zap.String("reason", reasons.Reason()),
+	)
+
 	// generate response
+	result := &metav1.Status{Message: reasons.Reason()}
+	if !reasons.IsAllowed() {
+		result.Details = &metav1.StatusDetails{Causes: reasons.Causes()}
+	}
+
 	ar.Response = &admissionv1.AdmissionResponse{
 		UID:     ar.Request.UID,
-		Allowed: failure.IsAllowed(),
-		Result:  &metav1.Status{Message: failure.Reason()},
+		Allowed: reasons.IsAllowed(),
+		Result:  result,
 	}
 
 	return nil
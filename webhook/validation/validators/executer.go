@@ -3,37 +3,114 @@ package validators
 import (
 	"context"
 	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/controllers/apps"
+	"github.com/mohammadne/sanjagh/pkg/rbac"
 	"github.com/mohammadne/sanjagh/webhook/validation/config"
 	"github.com/mohammadne/sanjagh/webhook/validation/failure"
 )
 
+var tracer = otel.Tracer("github.com/mohammadne/sanjagh/webhook/validation/validators")
+
 type executerValidator struct {
-	config *config.Config
+	config atomic.Pointer[config.Config]
 	client client.Reader
 }
 
 func NewExecuter(cfg *config.Config, client client.Reader) *executerValidator {
-	return &executerValidator{config: cfg, client: client}
+	v := &executerValidator{client: client}
+	v.config.Store(cfg)
+	return v
+}
+
+// Reload replaces the config of the validator
+func (v *executerValidator) Reload(cfg *config.Config) {
+	v.config.Store(cfg)
 }
 
 func (v *executerValidator) Validate(ctx context.Context, ar *admissionv1.AdmissionReview) (*failure.Failure, error) {
+	ctx, span := tracer.Start(ctx, "validators.Executer")
+	defer span.End()
+
 	executer, failure := &v1alpha1.Executer{}, &failure.Failure{}
 	if err := json.Unmarshal(ar.Request.Object.Raw, executer); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error decoding executer")
 		return nil, err
 	}
 	if executer.DeletionTimestamp != nil {
 		return nil, nil
 	}
 
+	// the namespace may be omitted from the object on creation
+	if executer.Namespace == "" {
+		executer.Namespace = ar.Request.Namespace
+	}
+
 	if err := v.ValidateReplication(ctx, executer, failure); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error validating replication")
+		return nil, err
+	}
+
+	if err := v.ValidateContainers(ctx, executer, failure); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error validating containers")
+		return nil, err
+	}
+
+	if err := v.ValidateVolumes(ctx, executer, failure); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error validating volumes")
+		return nil, err
+	}
+
+	if err := v.ValidateFiles(ctx, executer, failure); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error validating files")
+		return nil, err
+	}
+
+	if err := v.ValidateSecurity(ctx, executer, failure); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error validating security")
 		return nil, err
 	}
 
+	if err := v.ValidateRBAC(ctx, executer, failure); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error validating rbac")
+		return nil, err
+	}
+
+	if err := v.ValidateDisruption(ctx, executer, failure); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error validating disruption")
+		return nil, err
+	}
+
+	if err := v.ValidateStrategy(ctx, executer, failure); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error validating strategy")
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Bool("validation.allowed", failure.IsAllowed()))
 	return failure, nil
 }
 
@@ -43,15 +120,327 @@ const (
 )
 
 func (v *executerValidator) ValidateReplication(ctx context.Context, executer *v1alpha1.Executer, f *failure.Failure) error {
-	if executer.Spec.Replication < v.config.Replication.Minimum {
-		f.RegisterReason(LowReplication, v.config.Replication.Minimum)
+	cfg := v.config.Load()
+	if executer.Spec.Replication < cfg.Replication.Minimum {
+		f.RegisterReason(LowReplication, cfg.Replication.Minimum)
+		return nil
+	}
+
+	if executer.Spec.Replication > cfg.Replication.Maximum {
+		f.RegisterReason(HighReplication, cfg.Replication.Maximum)
+		return nil
+	}
+
+	return nil
+}
+
+const (
+	DuplicateContainer string = "Container name is duplicated: '%s'"
+	DisallowedImage    string = "Image of container '%s' is not from the allowed registries: '%s'"
+	LatestImage        string = "Image of container '%s' has no tag or the latest tag: '%s'"
+)
+
+// ValidateContainers checks the names of all the containers are unique and applies the image policy to them
+func (v *executerValidator) ValidateContainers(ctx context.Context, executer *v1alpha1.Executer, f *failure.Failure) error {
+	cfg := v.config.Load()
+
+	containers := []v1alpha1.Container{{Name: executer.Name, Image: executer.Spec.Image}}
+	containers = append(containers, executer.Spec.Sidecars...)
+	containers = append(containers, executer.Spec.InitContainers...)
+
+	names := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		if names[container.Name] {
+			f.RegisterReason(DuplicateContainer, container.Name)
+		}
+		names[container.Name] = true
+
+		image := normalizeImage(container.Image)
+		if len(cfg.Images.AllowedRegistries) > 0 && !allowedImage(image, cfg.Images.AllowedRegistries) {
+			f.RegisterReason(DisallowedImage, container.Name, container.Image)
+		}
+
+		if cfg.Images.DenyLatest && latestImage(image) {
+			f.RegisterReason(LatestImage, container.Name, container.Image)
+		}
+	}
+
+	return nil
+}
+
+// normalizeImage adds the implicit docker hub registry to the image, e.g. nginx is docker.io/library/nginx
+func normalizeImage(image string) string {
+	components := strings.Split(image, "/")
+	if len(components) == 1 {
+		return "docker.io/library/" + image
+	}
+
+	if domain := components[0]; !strings.ContainsAny(domain, ".:") && domain != "localhost" {
+		return "docker.io/" + image
+	}
+
+	return image
+}
+
+func allowedImage(image string, registries []string) bool {
+	for _, registry := range registries {
+		if strings.HasPrefix(image, strings.TrimSuffix(registry, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// latestImage reports whether the image refers to the latest tag explicitly or implicitly
+func latestImage(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+
+	name := image[strings.LastIndex(image, "/")+1:]
+	tag := ""
+	if index := strings.LastIndex(name, ":"); index >= 0 {
+		tag = name[index+1:]
+	}
+
+	return tag == "" || tag == "latest"
+}
+
+const (
+	DuplicateVolume string = "Volume name is duplicated: '%s'"
+	ReservedVolume  string = "Volume name is reserved for the inline files: '%s'"
+	VolumeSources   string = "Volume '%s' must have exactly one source, has %d"
+	UnknownVolume   string = "Container '%s' mounts an unknown volume: '%s'"
+)
+
+// ValidateVolumes checks each volume has a single source and the mounts of all the containers refer to them
+func (v *executerValidator) ValidateVolumes(ctx context.Context, executer *v1alpha1.Executer, f *failure.Failure) error {
+	volumes := make(map[string]bool, len(executer.Spec.Volumes))
+	for _, volume := range executer.Spec.Volumes {
+		if volumes[volume.Name] {
+			f.RegisterReason(DuplicateVolume, volume.Name)
+		} else if volume.Name == apps.FilesVolume {
+			f.RegisterReason(ReservedVolume, volume.Name)
+		}
+		volumes[volume.Name] = true
+
+		sources := 0
+		for _, set := range []bool{
+			volume.ConfigMap != nil, volume.Secret != nil, volume.EmptyDir != nil, volume.Projected != nil,
+			volume.HostPath != nil, volume.PersistentVolumeClaim != nil, volume.ClaimTemplate != nil,
+		} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			f.RegisterReason(VolumeSources, volume.Name, sources)
+		}
+	}
+
+	containers := []v1alpha1.Container{{Name: executer.Name, VolumeMounts: executer.Spec.VolumeMounts}}
+	containers = append(containers, executer.Spec.Sidecars...)
+	containers = append(containers, executer.Spec.InitContainers...)
+	for _, container := range containers {
+		for _, mount := range container.VolumeMounts {
+			if !volumes[mount.Name] {
+				f.RegisterReason(UnknownVolume, container.Name, mount.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+const (
+	InvalidFilePath   string = "File path must be an absolute and clean path: '%s'"
+	InvalidFileKey    string = "File path can't be used as a ConfigMap key: '%s'"
+	DuplicateFileKeys string = "File paths '%s' and '%s' have the same ConfigMap key"
+)
+
+// ValidateFiles checks the inline files are mountable and can be kept in a single ConfigMap
+func (v *executerValidator) ValidateFiles(ctx context.Context, executer *v1alpha1.Executer, f *failure.Failure) error {
+	paths := make([]string, 0, len(executer.Spec.Files))
+	for path := range executer.Spec.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	keys := make(map[string]string, len(paths))
+	for _, path := range paths {
+		if !filepath.IsAbs(path) || filepath.Clean(path) != path || path == "/" {
+			f.RegisterReason(InvalidFilePath, path)
+			continue
+		}
+
+		key := apps.FileKey(path)
+		if len(validation.IsConfigMapKey(key)) > 0 {
+			f.RegisterReason(InvalidFileKey, path)
+			continue
+		}
+
+		if previous, ok := keys[key]; ok {
+			f.RegisterReason(DuplicateFileKeys, previous, path)
+		}
+		keys[key] = path
+	}
+
+	return nil
+}
+
+const (
+	PrivilegedContainer string = "Container '%s' is privileged, which is only allowed in the privileged namespaces"
+	HostPathVolume      string = "Volume '%s' mounts a host path, which is only allowed in the privileged namespaces"
+)
+
+// ValidateSecurity rejects privileged containers and host path volumes outside the privileged namespaces
+func (v *executerValidator) ValidateSecurity(ctx context.Context, executer *v1alpha1.Executer, f *failure.Failure) error {
+	for _, namespace := range v.config.Load().Security.PrivilegedNamespaces {
+		if namespace == executer.Namespace {
+			return nil
+		}
+	}
+
+	containers := []v1alpha1.Container{{Name: executer.Name, SecurityContext: executer.Spec.ContainerSecurityContext}}
+	containers = append(containers, executer.Spec.Sidecars...)
+	containers = append(containers, executer.Spec.InitContainers...)
+	for _, container := range containers {
+		if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+			f.RegisterReason(PrivilegedContainer, container.Name)
+		}
+	}
+
+	for _, volume := range executer.Spec.Volumes {
+		if volume.HostPath != nil {
+			f.RegisterReason(HostPathVolume, volume.Name)
+		}
+	}
+
+	return nil
+}
+
+const (
+	ServiceAccountWithRBAC string = "ServiceAccountName can't be set together with rbac: '%s'"
+	NonResourceRule        string = "Rule %d of rbac can't grant non-resource URLs"
+	EscalatingRule         string = "Rule %d of rbac escalates beyond the ceiling: verb '%s' on resource '%s' of api group '%s'"
+)
+
+// ValidateRBAC checks the rules of the provisioned role are covered by the ceiling, so executers can't escalate their privileges
+func (v *executerValidator) ValidateRBAC(ctx context.Context, executer *v1alpha1.Executer, f *failure.Failure) error {
+	if executer.Spec.RBAC == nil {
+		return nil
+	}
+
+	if executer.Spec.ServiceAccountName != "" {
+		f.RegisterReason(ServiceAccountWithRBAC, executer.Spec.ServiceAccountName)
+	}
+
+	ceiling := v.config.Load().RBAC.Ceiling
+	for index, rule := range executer.Spec.RBAC.Rules {
+		if len(rule.NonResourceURLs) > 0 {
+			f.RegisterReason(NonResourceRule, index)
+		}
+
+		for _, permission := range rbac.EscalatingPermissions(ceiling, rule) {
+			f.RegisterReason(EscalatingRule, index, permission.Verb, permission.Resource, permission.APIGroup)
+		}
+	}
+
+	return nil
+}
+
+const (
+	DisruptionFields  string = "Disruption can't have both minAvailable and maxUnavailable"
+	InvalidDisruption string = "Disruption %s must be a non-negative number or a percentage between 0%% and 100%%: '%s'"
+)
+
+// ValidateDisruption checks the PodDisruptionBudget of the executer can be created
+func (v *executerValidator) ValidateDisruption(ctx context.Context, executer *v1alpha1.Executer, f *failure.Failure) error {
+	disruption := executer.Spec.Disruption
+	if disruption == nil {
 		return nil
 	}
 
-	if executer.Spec.Replication > v.config.Replication.Maximum {
-		f.RegisterReason(HighReplication, v.config.Replication.Maximum)
+	if disruption.MinAvailable != nil && disruption.MaxUnavailable != nil {
+		f.RegisterReason(DisruptionFields)
+	}
+
+	if value := disruption.MinAvailable; value != nil && !validIntOrPercentage(*value) {
+		f.RegisterReason(InvalidDisruption, "minAvailable", value.String())
+	}
+
+	if value := disruption.MaxUnavailable; value != nil && !validIntOrPercentage(*value) {
+		f.RegisterReason(InvalidDisruption, "maxUnavailable", value.String())
+	}
+
+	return nil
+}
+
+func validIntOrPercentage(value intstr.IntOrString) bool {
+	if value.Type == intstr.Int {
+		return value.IntVal >= 0
+	}
+
+	if !strings.HasSuffix(value.StrVal, "%") {
+		return false
+	}
+
+	number, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+	return err == nil && number >= 0 && number <= 100
+}
+
+const (
+	StrategyParameters   string = "Strategy '%s' can't have the parameters of %s"
+	InvalidRollingUpdate string = "Rolling update %s must be a non-negative number or a percentage between 0%% and 100%%: '%s'"
+	InvalidCanary        string = "Canary %s can't be negative: '%s'"
+	ShortCanaryDeadline  string = "Canary progressDeadline must be at least a second: '%s'"
+)
+
+// ValidateStrategy checks the parameters of the rollouts belong to their type and are valid
+func (v *executerValidator) ValidateStrategy(ctx context.Context, executer *v1alpha1.Executer, f *failure.Failure) error {
+	strategy := executer.Spec.Strategy
+	if strategy == nil {
 		return nil
 	}
 
+	strategyType := strategy.Type
+	if strategyType == "" {
+		strategyType = v1alpha1.StrategyRollingUpdate
+	}
+
+	if rollingUpdate := strategy.RollingUpdate; rollingUpdate != nil {
+		if strategyType == v1alpha1.StrategyRecreate {
+			f.RegisterReason(StrategyParameters, strategyType, "rollingUpdate")
+		}
+
+		if value := rollingUpdate.MaxSurge; value != nil && !validIntOrPercentage(*value) {
+			f.RegisterReason(InvalidRollingUpdate, "maxSurge", value.String())
+		}
+
+		if value := rollingUpdate.MaxUnavailable; value != nil && !validIntOrPercentage(*value) {
+			f.RegisterReason(InvalidRollingUpdate, "maxUnavailable", value.String())
+		}
+	}
+
+	if canary := strategy.Canary; canary != nil {
+		if strategyType != v1alpha1.StrategyCanary {
+			f.RegisterReason(StrategyParameters, strategyType, "canary")
+		}
+
+		if canary.Replicas < 0 {
+			f.RegisterReason(InvalidCanary, "replicas", strconv.Itoa(int(canary.Replicas)))
+		}
+
+		if canary.Duration.Duration < 0 {
+			f.RegisterReason(InvalidCanary, "duration", canary.Duration.Duration.String())
+		}
+
+		if deadline := canary.ProgressDeadline.Duration; deadline < 0 {
+			f.RegisterReason(InvalidCanary, "progressDeadline", deadline.String())
+		} else if deadline > 0 && deadline < time.Second {
+			f.RegisterReason(ShortCanaryDeadline, deadline.String())
+		}
+	}
+
 	return nil
 }
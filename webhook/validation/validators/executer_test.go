@@ -0,0 +1,209 @@
+package validators_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+
+	"github.com/mohammadne/sanjagh/api/v1alpha1"
+	"github.com/mohammadne/sanjagh/pkg/rbac"
+	"github.com/mohammadne/sanjagh/webhook/validation/config"
+	"github.com/mohammadne/sanjagh/webhook/validation/failure"
+	"github.com/mohammadne/sanjagh/webhook/validation/validators"
+)
+
+func newExecuter() *v1alpha1.Executer {
+	executer := &v1alpha1.Executer{Spec: v1alpha1.ExecuterSpec{Image: "nginx:1.25", Replication: 2}}
+	executer.Name = "sample"
+	return executer
+}
+
+func TestValidateContainersDuplicateNames(t *testing.T) {
+	executer := newExecuter()
+	executer.Spec.Sidecars = []v1alpha1.Container{{Name: "proxy", Image: "envoy:v1"}}
+	executer.Spec.InitContainers = []v1alpha1.Container{{Name: "sample", Image: "busybox:1"}, {Name: "proxy", Image: "busybox:1"}}
+
+	var f failure.Failure
+	require.NoError(t, validators.NewExecuter(&config.Config{}, nil).ValidateContainers(context.Background(), executer, &f))
+	assert.Equal(t, failure.Failure{
+		"Container name is duplicated: 'sample'",
+		"Container name is duplicated: 'proxy'",
+	}, f)
+}
+
+func TestValidateContainersImagePolicy(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Images.AllowedRegistries = []string{"docker.io/library", "ghcr.io/mohammadne/"}
+	cfg.Images.DenyLatest = true
+
+	executer := newExecuter()
+	executer.Spec.Sidecars = []v1alpha1.Container{
+		{Name: "shipper", Image: "ghcr.io/mohammadne/shipper@sha256:abcd"},
+		{Name: "proxy", Image: "quay.io/envoy:v1"},
+	}
+	executer.Spec.InitContainers = []v1alpha1.Container{
+		{Name: "migrate", Image: "ghcr.io/mohammadne/migrate"},
+		{Name: "fetch", Image: "localhost:5000/fetch:latest"},
+	}
+
+	var f failure.Failure
+	require.NoError(t, validators.NewExecuter(cfg, nil).ValidateContainers(context.Background(), executer, &f))
+	assert.Equal(t, failure.Failure{
+		"Image of container 'proxy' is not from the allowed registries: 'quay.io/envoy:v1'",
+		"Image of container 'migrate' has no tag or the latest tag: 'ghcr.io/mohammadne/migrate'",
+		"Image of container 'fetch' is not from the allowed registries: 'localhost:5000/fetch:latest'",
+		"Image of container 'fetch' has no tag or the latest tag: 'localhost:5000/fetch:latest'",
+	}, f)
+}
+
+func TestValidateVolumes(t *testing.T) {
+	executer := newExecuter()
+	executer.Spec.Volumes = []v1alpha1.Volume{
+		{Name: "config", ConfigMap: &corev1.ConfigMapVolumeSource{}},
+		{Name: "data", EmptyDir: &corev1.EmptyDirVolumeSource{}, ClaimTemplate: &corev1.PersistentVolumeClaimSpec{}},
+		{Name: "config", Secret: &corev1.SecretVolumeSource{}},
+	}
+	executer.Spec.VolumeMounts = []corev1.VolumeMount{{Name: "config", MountPath: "/etc/config"}}
+	executer.Spec.Sidecars = []v1alpha1.Container{{Name: "shipper", VolumeMounts: []corev1.VolumeMount{{Name: "logs", MountPath: "/logs"}}}}
+
+	var f failure.Failure
+	require.NoError(t, validators.NewExecuter(&config.Config{}, nil).ValidateVolumes(context.Background(), executer, &f))
+	assert.Equal(t, failure.Failure{
+		"Volume 'data' must have exactly one source, has 2",
+		"Volume name is duplicated: 'config'",
+		"Container 'shipper' mounts an unknown volume: 'logs'",
+	}, f)
+}
+
+func TestValidateFiles(t *testing.T) {
+	executer := newExecuter()
+	executer.Spec.Files = map[string]string{
+		"/etc/app/config.yaml": "debug: true",
+		"/etc/app_config.yaml": "debug: false",
+		"etc/relative.yaml":    "",
+		"/etc/app/../x.yaml":   "",
+		"/etc/app/con fig":     "",
+	}
+
+	var f failure.Failure
+	require.NoError(t, validators.NewExecuter(&config.Config{}, nil).ValidateFiles(context.Background(), executer, &f))
+	assert.Equal(t, failure.Failure{
+		"File path must be an absolute and clean path: '/etc/app/../x.yaml'",
+		"File path can't be used as a ConfigMap key: '/etc/app/con fig'",
+		"File paths '/etc/app/config.yaml' and '/etc/app_config.yaml' have the same ConfigMap key",
+		"File path must be an absolute and clean path: 'etc/relative.yaml'",
+	}, f)
+}
+
+func TestValidateSecurity(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Security.PrivilegedNamespaces = []string{"kube-system"}
+
+	executer := newExecuter()
+	executer.Namespace = "default"
+	executer.Spec.ContainerSecurityContext = &corev1.SecurityContext{Privileged: pointer.Bool(false)}
+	executer.Spec.Sidecars = []v1alpha1.Container{{Name: "agent", SecurityContext: &corev1.SecurityContext{Privileged: pointer.Bool(true)}}}
+	executer.Spec.Volumes = []v1alpha1.Volume{{Name: "logs", HostPath: &corev1.HostPathVolumeSource{Path: "/var/log"}}}
+
+	var f failure.Failure
+	require.NoError(t, validators.NewExecuter(cfg, nil).ValidateSecurity(context.Background(), executer, &f))
+	assert.Equal(t, failure.Failure{
+		"Container 'agent' is privileged, which is only allowed in the privileged namespaces",
+		"Volume 'logs' mounts a host path, which is only allowed in the privileged namespaces",
+	}, f)
+
+	executer.Namespace = "kube-system"
+	f = failure.Failure{}
+	require.NoError(t, validators.NewExecuter(cfg, nil).ValidateSecurity(context.Background(), executer, &f))
+	assert.True(t, f.IsAllowed())
+}
+
+func TestValidateRBAC(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.RBAC.Ceiling = []rbac.Rule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps", "pods"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{"batch"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+	}
+
+	executer := newExecuter()
+	executer.Spec.ServiceAccountName = "default"
+	executer.Spec.RBAC = &v1alpha1.RBAC{Rules: []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "delete"}},
+		{APIGroups: []string{"batch"}, Resources: []string{"jobs", "cronjobs"}, Verbs: []string{"create"}},
+		{APIGroups: []string{"*"}, Resources: []string{"secrets"}, Verbs: []string{"list"}, NonResourceURLs: []string{"/healthz"}},
+	}}
+
+	var f failure.Failure
+	require.NoError(t, validators.NewExecuter(cfg, nil).ValidateRBAC(context.Background(), executer, &f))
+	assert.Equal(t, failure.Failure{
+		"ServiceAccountName can't be set together with rbac: 'default'",
+		"Rule 0 of rbac escalates beyond the ceiling: verb 'delete' on resource 'pods' of api group ''",
+		"Rule 2 of rbac can't grant non-resource URLs",
+		"Rule 2 of rbac escalates beyond the ceiling: verb 'list' on resource 'secrets' of api group '*'",
+	}, f)
+}
+
+func TestValidateDisruption(t *testing.T) {
+	minAvailable, maxUnavailable := intstr.FromString("150%"), intstr.FromInt(-1)
+
+	executer := newExecuter()
+	executer.Spec.Disruption = &v1alpha1.Disruption{MinAvailable: &minAvailable, MaxUnavailable: &maxUnavailable}
+
+	var f failure.Failure
+	require.NoError(t, validators.NewExecuter(&config.Config{}, nil).ValidateDisruption(context.Background(), executer, &f))
+	assert.Equal(t, failure.Failure{
+		"Disruption can't have both minAvailable and maxUnavailable",
+		"Disruption minAvailable must be a non-negative number or a percentage between 0% and 100%: '150%'",
+		"Disruption maxUnavailable must be a non-negative number or a percentage between 0% and 100%: '-1'",
+	}, f)
+
+	maxUnavailable = intstr.FromString("25%")
+	executer.Spec.Disruption = &v1alpha1.Disruption{MaxUnavailable: &maxUnavailable}
+
+	f = failure.Failure{}
+	require.NoError(t, validators.NewExecuter(&config.Config{}, nil).ValidateDisruption(context.Background(), executer, &f))
+	assert.True(t, f.IsAllowed())
+}
+
+func TestValidateStrategy(t *testing.T) {
+	maxSurge := intstr.FromString("50")
+
+	executer := newExecuter()
+	executer.Spec.Strategy = &v1alpha1.Strategy{
+		Type:          v1alpha1.StrategyRecreate,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: &maxSurge},
+		Canary: &v1alpha1.Canary{
+			Replicas:         -1,
+			Duration:         metav1.Duration{Duration: -time.Minute},
+			ProgressDeadline: metav1.Duration{Duration: time.Millisecond},
+		},
+	}
+
+	var f failure.Failure
+	require.NoError(t, validators.NewExecuter(&config.Config{}, nil).ValidateStrategy(context.Background(), executer, &f))
+	assert.Equal(t, failure.Failure{
+		"Strategy 'Recreate' can't have the parameters of rollingUpdate",
+		"Rolling update maxSurge must be a non-negative number or a percentage between 0% and 100%: '50'",
+		"Strategy 'Recreate' can't have the parameters of canary",
+		"Canary replicas can't be negative: '-1'",
+		"Canary duration can't be negative: '-1m0s'",
+		"Canary progressDeadline must be at least a second: '1ms'",
+	}, f)
+
+	maxSurge = intstr.FromString("50%")
+	executer.Spec.Strategy.Type = v1alpha1.StrategyCanary
+	executer.Spec.Strategy.Canary = &v1alpha1.Canary{Replicas: 1, Duration: metav1.Duration{Duration: time.Minute}}
+
+	f = failure.Failure{}
+	require.NoError(t, validators.NewExecuter(&config.Config{}, nil).ValidateStrategy(context.Background(), executer, &f))
+	assert.True(t, f.IsAllowed())
+}